@@ -0,0 +1,93 @@
+package cryptonight
+
+import "unsafe"
+
+// ScratchpadSize is the size in bytes of the scratchpad every Cache needs, as
+// passed to ScratchpadAllocator.Alloc.
+const ScratchpadSize = scratchpadSize
+
+// ScratchpadAllocator supplies the scratchpads of caches created by
+// NewCacheWithAllocator, for deployments that need a specific kind of memory,
+// such as huge pages, memory of a NUMA node, or locked memory.
+//
+// Alloc returns memory of size bytes, that is size/8 words; it need not be
+// zeroed, as Sum overwrites all of it before reading. The memory must stay
+// valid and unused by anything else until it is passed back to Free, which
+// happens exactly once, when the Cache is freed. A Cache never retains the
+// memory past Free, so an allocator may hand it out again afterwards.
+//
+// Memory not managed by the Go runtime must not be passed to Free of another
+// allocator, and an allocator may be called from several goroutines at once
+// if it is shared by caches that are.
+//
+// The memory may be a region of a mapping shared between processes, such as
+// one of a pool of huge-page scratchpads owned by a supervisor process. Only
+// the scratchpad lives there; the rest of the Cache stays on the Go heap of the
+// process using it. As within a process, every region must back a single
+// Cache at a time, in a single process, so hashing across processes needs one
+// region per Cache.
+type ScratchpadAllocator interface {
+	Alloc(size int) ([]uint64, error)
+	Free([]uint64)
+}
+
+// HeapAllocator is the ScratchpadAllocator of a Cache without one: it
+// allocates on the Go heap, and leaves freed memory to the GC.
+type HeapAllocator struct{}
+
+// Alloc allocates size/8 words on the Go heap.
+func (HeapAllocator) Alloc(size int) ([]uint64, error) {
+	return make([]uint64, size/8), nil
+}
+
+// Free does nothing; the GC reclaims the memory once it is unreachable.
+func (HeapAllocator) Free([]uint64) {}
+
+// NewCacheWithAllocator returns a new Cache whose scratchpad is allocated by
+// alloc right away, and returns the error of alloc if any, such as an
+// *AllocError of HugePageAllocator. It returns ErrInvalidScratchpad if alloc
+// returns less than ScratchpadSize bytes.
+//
+// It is the only constructor of Cache that can fail. new(Cache), NewCache and
+// the pools allocate on the Go heap, where running out of memory is a fatal
+// runtime error rather than an error value, so a deployment that has to
+// degrade gracefully under memory pressure should allocate through it.
+//
+// The Cache holds the scratchpad until Free. As any Cache, it works with every
+// variant.
+func NewCacheWithAllocator(alloc ScratchpadAllocator) (*Cache, error) {
+	s, err := alloc.Alloc(ScratchpadSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) < ScratchpadSize/8 {
+		alloc.Free(s)
+		return nil, ErrInvalidScratchpad
+	}
+
+	return &Cache{
+		scratchpad: (*[scratchpadSize / 8]uint64)(unsafe.Pointer(&s[0])),
+		alloc:      alloc,
+	}, nil
+}
+
+// Free returns the scratchpad of cache to the allocator it came from, if any.
+// cache must not be in use. cache may be used again afterwards, in which case
+// it allocates a new scratchpad on the heap, as a zero Cache does.
+//
+// Calling Free is only required for a Cache created by NewCacheWithAllocator
+// with an allocator whose memory the GC doesn't reclaim.
+func (cache *Cache) Free() {
+	if cache.alloc != nil {
+		cache.alloc.Free(cache.scratchpad[:])
+	}
+	cache.scratchpad = nil
+	cache.alloc = nil
+}
+
+// allocScratchpad allocates the scratchpad on the heap if cache has none yet.
+func (cache *Cache) allocScratchpad() {
+	if cache.scratchpad == nil {
+		cache.scratchpad = new([scratchpadSize / 8]uint64)
+	}
+}
@@ -0,0 +1,84 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"reflect"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// regionAllocator hands out the scratchpad-sized regions of a single mapping,
+// one per Cache.
+type regionAllocator struct {
+	mu      sync.Mutex
+	regions [][]uint64
+}
+
+func (a *regionAllocator) Alloc(size int) ([]uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.regions[len(a.regions)-1]
+	a.regions = a.regions[:len(a.regions)-1]
+	return s, nil
+}
+
+func (a *regionAllocator) Free(s []uint64) {
+	a.mu.Lock()
+	a.regions = append(a.regions, s)
+	a.mu.Unlock()
+}
+
+func TestSharedMappingAllocator(t *testing.T) {
+	// a shared mapping, as another process would attach to, of two regions
+	const regions = 2
+	b, err := unix.Mmap(-1, 0, regions*ScratchpadSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_ANONYMOUS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	defer unix.Munmap(b)
+
+	var words []uint64
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&words))
+	h.Data = uintptr(unsafe.Pointer(&b[0]))
+	h.Len = len(b) / 8
+	h.Cap = h.Len
+
+	alloc := new(regionAllocator)
+	for i := 0; i < regions; i++ {
+		alloc.regions = append(alloc.regions, words[i*ScratchpadSize/8:(i+1)*ScratchpadSize/8])
+	}
+
+	// two goroutines, each owning a distinct region
+	var wg sync.WaitGroup
+	for w := 0; w < regions; w++ {
+		cache, err := NewCacheWithAllocator(alloc)
+		if err != nil {
+			t.Fatalf("unexpected error: %v\n", err)
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			defer cache.Free()
+
+			for i := range hashSpecsSample {
+				v := hashSpecsSample[(i+w)%len(hashSpecsSample)]
+				in, _ := hex.DecodeString(v.input)
+				if result := hex.EncodeToString(cache.Sum(in, v.variant)); result != v.output {
+					t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", w, v.output, result)
+				}
+			}
+
+			// the scratchpad is in the mapping, where another process sees it
+			addr := uintptr(unsafe.Pointer(&cache.Scratchpad()[0]))
+			if start := uintptr(unsafe.Pointer(&b[0])); addr < start || addr >= start+uintptr(len(b)) {
+				t.Errorf("[%d] expected the scratchpad in the shared mapping\n", w)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,104 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// countingAllocator is HeapAllocator that counts its calls, and returns err
+// or a scratchpad of size words less than asked if set.
+type countingAllocator struct {
+	allocs, frees int
+	short         int
+	err           error
+}
+
+func (a *countingAllocator) Alloc(size int) ([]uint64, error) {
+	a.allocs++
+	if a.err != nil {
+		return nil, a.err
+	}
+	if size != ScratchpadSize {
+		panic("unexpected size")
+	}
+
+	return make([]uint64, size/8-a.short), nil
+}
+
+func (a *countingAllocator) Free([]uint64) {
+	a.frees++
+}
+
+func TestNewCacheWithAllocator(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+
+	alloc := new(countingAllocator)
+	cache, err := NewCacheWithAllocator(alloc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	for i := 0; i < 2; i++ {
+		if result := hex.EncodeToString(cache.Sum(in, 2)); result != hashSpecsV2[0].output {
+			t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", hashSpecsV2[0].output, result)
+		}
+	}
+	if alloc.allocs != 1 || alloc.frees != 0 {
+		t.Errorf("expected 1 Alloc and no Free, got %d and %d\n", alloc.allocs, alloc.frees)
+	}
+
+	// after Free, the Cache falls back to the heap
+	cache.Free()
+	cache.Free()
+	if alloc.frees != 1 {
+		t.Errorf("expected 1 Free, got %d\n", alloc.frees)
+	}
+	if result := hex.EncodeToString(cache.Sum(in, 2)); result != hashSpecsV2[0].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", hashSpecsV2[0].output, result)
+	}
+	if alloc.allocs != 1 {
+		t.Errorf("expected no more Alloc, got %d\n", alloc.allocs)
+	}
+
+	// errors
+	alloc = &countingAllocator{err: errors.New("out of memory")}
+	if _, err := NewCacheWithAllocator(alloc); err != alloc.err {
+		t.Errorf("expected %v, got %v\n", alloc.err, err)
+	}
+	alloc = &countingAllocator{short: 1}
+	if _, err := NewCacheWithAllocator(alloc); err != ErrInvalidScratchpad {
+		t.Errorf("expected ErrInvalidScratchpad, got %v\n", err)
+	}
+	if alloc.frees != 1 {
+		t.Errorf("expected the short scratchpad freed, got %d Free\n", alloc.frees)
+	}
+}
+
+func TestAllocators(t *testing.T) {
+	for name, alloc := range map[string]ScratchpadAllocator{
+		"heap":             HeapAllocator{},
+		"huge page":        HugePageAllocator{},
+		"locked huge page": HugePageAllocator{Lock: true},
+	} {
+		cache, err := NewCacheWithAllocator(alloc)
+		if err == ErrHugePagesUnsupported {
+			t.Logf("%s: not supported on this platform\n", name)
+			continue
+		}
+		if ae, ok := err.(*AllocError); ok && ae.Op == "mlock" {
+			t.Logf("%s: not allowed by RLIMIT_MEMLOCK: %v\n", name, err)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", name, err)
+		}
+
+		for _, v := range hashSpecsSample {
+			in, _ := hex.DecodeString(v.input)
+			if result := hex.EncodeToString(cache.Sum(in, v.variant)); result != v.output {
+				t.Errorf("\n%s [%d] expected:\n\t%s\ngot:\n\t%s\n", name, v.variant, v.output, result)
+			}
+		}
+		cache.Free()
+	}
+}
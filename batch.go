@@ -0,0 +1,128 @@
+package cryptonight
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// SumBatch calculates the CryptoNight hash digest of each blob in blobs,
+// using workers goroutines with one Cache each. The i-th digest of the result
+// belongs to blobs[i].
+//
+// workers of 0 means runtime.GOMAXPROCS(0). Workers are capped at len(blobs),
+// as any goroutine beyond that would have nothing to do. Blobs are distributed
+// evenly, so the workers differ by at most one blob in their share. A negative
+// workers returns ErrInvalidWorkers.
+//
+// Every worker runs all the stages of each of its hashes, from the keccak
+// state to the final hash, on its own Cache, and the workers share nothing but
+// blobs and the result, so they never wait for each other. The throughput thus
+// grows with workers up to the number of cores, as long as the memory system
+// keeps up with the scratchpads; see BenchmarkSumBatch for the scaling on a
+// specific CPU.
+//
+// The same requirement on input length of Sum applies to every blob, but
+// SumBatch returns ErrBlobTooShort instead of panicking, before hashing any of
+// them.
+func SumBatch(blobs [][]byte, variant int, workers int) ([][]byte, error) {
+	p := paramsOf(variant)
+	if err := checkBatch(blobs, p); err != nil {
+		return nil, err
+	}
+	sums := make([][]byte, len(blobs))
+	err := runBatch(len(blobs), workers, func(cache *Cache, i int) {
+		sums[i] = cache.sum(blobs[i], p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+// SumBatchInto is like SumBatch, but it writes the digests contiguously into
+// dst, the i-th digest at dst[32*i:32*(i+1)], without allocating them one by
+// one. It returns io.ErrShortBuffer and writes nothing if dst is shorter than
+// 32*len(blobs) bytes, and so does it with ErrBlobTooShort.
+func SumBatchInto(dst []byte, blobs [][]byte, variant int, workers int) error {
+	if len(dst) < 32*len(blobs) {
+		return io.ErrShortBuffer
+	}
+
+	p := paramsOf(variant)
+	if err := checkBatch(blobs, p); err != nil {
+		return err
+	}
+	return runBatch(len(blobs), workers, func(cache *Cache, i int) {
+		cache.sumAppend(dst[32*i:32*i], blobs[i], p)
+	})
+}
+
+// SumManyWithDifficulty is like SumBatch, but it returns the digests as Digest
+// together with their difficulties, as computed by Difficulty, so a pool can
+// update its statistics of a batch of shares in one pass. The difficulties are
+// calculated by the workers right after hashing.
+//
+// parallelism has the same meaning as workers of SumBatch, and it returns the
+// same errors, ErrInvalidWorkers and ErrBlobTooShort.
+func SumManyWithDifficulty(blobs [][]byte, variant int, parallelism int) ([]Digest, []uint64, error) {
+	p := paramsOf(variant)
+	if err := checkBatch(blobs, p); err != nil {
+		return nil, nil, err
+	}
+	sums := make([]Digest, len(blobs))
+	diffs := make([]uint64, len(blobs))
+	err := runBatch(len(blobs), parallelism, func(cache *Cache, i int) {
+		cache.sumAppend(sums[i][:0], blobs[i], p)
+		diffs[i] = Difficulty(sums[i][:])
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sums, diffs, nil
+}
+
+// checkBatch returns ErrBlobTooShort if any of blobs is too short for p. It
+// runs before runBatch, as a panic in one of its workers can't be recovered by
+// the caller.
+func checkBatch(blobs [][]byte, p *variantParams) error {
+	for _, blob := range blobs {
+		if len(blob) < p.minLen {
+			return ErrBlobTooShort
+		}
+	}
+
+	return nil
+}
+
+// runBatch calls fn for each of n jobs, over workers goroutines with one
+// Cache each, as described by SumBatch.
+func runBatch(n, workers int, fn func(cache *Cache, i int)) error {
+	if workers < 0 {
+		return ErrInvalidWorkers
+	}
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+
+			cache := new(Cache)
+			for i := w; i < n; i += workers {
+				fn(cache, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	return nil
+}
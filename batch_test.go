@@ -0,0 +1,184 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSumBatch(t *testing.T) {
+	blobs := make([][]byte, len(hashSpecsV2))
+	for i, v := range hashSpecsV2 {
+		blobs[i], _ = hex.DecodeString(v.input)
+	}
+
+	for _, workers := range []int{0, 1, 3, len(blobs) + 5} {
+		sums, err := SumBatch(blobs, 2, workers)
+		if err != nil {
+			t.Fatalf("workers=%d: unexpected error: %v\n", workers, err)
+		}
+		if len(sums) != len(blobs) {
+			t.Fatalf("workers=%d: expected %d digests, got %d\n", workers, len(blobs), len(sums))
+		}
+		for i, v := range hashSpecsV2 {
+			if hex.EncodeToString(sums[i]) != v.output {
+				t.Errorf("\nworkers=%d [%d] expected:\n\t%s\ngot:\n\t%x\n", workers, i, v.output, sums[i])
+			}
+		}
+	}
+
+	if sums, err := SumBatch(nil, 0, 0); err != nil || len(sums) != 0 {
+		t.Errorf("expected no digests and no error on empty batch, got %d, %v\n", len(sums), err)
+	}
+	if _, err := SumBatch(blobs, 2, -1); err != ErrInvalidWorkers {
+		t.Errorf("expected ErrInvalidWorkers, got %v\n", err)
+	}
+	if _, err := SumBatch(append(blobs, make([]byte, 42)), 1, 0); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+}
+
+func TestSumBatchSerial(t *testing.T) {
+	// arbitrary blobs of a block hashing blob's size, against Sum one by one
+	x := uint64(0x9e3779b97f4a7c15)
+	blobs := make([][]byte, 6)
+	for i := range blobs {
+		blobs[i] = make([]byte, 76)
+		for j := range blobs[i] {
+			x = x*6364136223846793005 + 1442695040888963407
+			blobs[i][j] = byte(x >> 56)
+		}
+	}
+
+	for _, variant := range SupportedVariants() {
+		sums, err := SumBatch(blobs, variant, 4)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", variant, err)
+		}
+		for i, blob := range blobs {
+			if expected := Sum(blob, variant); !bytes.Equal(sums[i], expected) {
+				t.Errorf("\n[%d] blob %d expected:\n\t%x\ngot:\n\t%x\n", variant, i, expected, sums[i])
+			}
+		}
+	}
+}
+
+func TestRunBatchConcurrent(t *testing.T) {
+	// every worker is inside fn at the same time, so no stage of a hash is
+	// serialized across workers, whatever the number of CPUs
+	const workers = 4
+	var arrived sync.WaitGroup
+	arrived.Add(workers)
+	all := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(all)
+	}()
+
+	runBatch(workers, workers, func(cache *Cache, i int) {
+		arrived.Done()
+		select {
+		case <-all:
+		case <-time.After(10 * time.Second):
+			t.Errorf("[%d] expected all %d workers to run at once\n", i, workers)
+		}
+	})
+}
+
+func TestSumBatchInto(t *testing.T) {
+	blobs := make([][]byte, len(hashSpecsV2))
+	for i, v := range hashSpecsV2 {
+		blobs[i], _ = hex.DecodeString(v.input)
+	}
+
+	for _, workers := range []int{0, 1, 3} {
+		dst := make([]byte, 32*len(blobs)+1)
+		dst[len(dst)-1] = 0xaa
+		if err := SumBatchInto(dst, blobs, 2, workers); err != nil {
+			t.Fatalf("workers=%d: unexpected error: %v\n", workers, err)
+		}
+		for i, v := range hashSpecsV2 {
+			if hex.EncodeToString(dst[32*i:32*(i+1)]) != v.output {
+				t.Errorf("\nworkers=%d [%d] expected:\n\t%s\ngot:\n\t%x\n", workers, i, v.output, dst[32*i:32*(i+1)])
+			}
+		}
+		if dst[len(dst)-1] != 0xaa {
+			t.Errorf("workers=%d: wrote past the digests\n", workers)
+		}
+	}
+
+	if err := SumBatchInto(make([]byte, 32*len(blobs)-1), blobs, 2, 0); err != io.ErrShortBuffer {
+		t.Errorf("expected io.ErrShortBuffer, got %v\n", err)
+	}
+	if err := SumBatchInto(make([]byte, 32*len(blobs)), blobs, 2, -1); err != ErrInvalidWorkers {
+		t.Errorf("expected ErrInvalidWorkers, got %v\n", err)
+	}
+	short := [][]byte{make([]byte, 43), make([]byte, 42)}
+	dst := make([]byte, 32*len(short))
+	if err := SumBatchInto(dst, short, 1, 0); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+	if !bytes.Equal(dst, make([]byte, len(dst))) {
+		t.Error("expected nothing written on ErrBlobTooShort")
+	}
+}
+
+func TestSumManyWithDifficulty(t *testing.T) {
+	blobs := make([][]byte, len(hashSpecsV2))
+	for i, v := range hashSpecsV2 {
+		blobs[i], _ = hex.DecodeString(v.input)
+	}
+
+	sums, diffs, err := SumManyWithDifficulty(blobs, 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if len(sums) != len(blobs) || len(diffs) != len(blobs) {
+		t.Fatalf("expected %d results, got %d digests and %d difficulties\n", len(blobs), len(sums), len(diffs))
+	}
+	for i, v := range hashSpecsV2 {
+		if sums[i].String() != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, v.output, sums[i])
+		}
+		if diff := sums[i].Difficulty(); diffs[i] != diff {
+			t.Errorf("[%d] expected difficulty %d, got %d\n", i, diff, diffs[i])
+		}
+	}
+
+	if _, _, err := SumManyWithDifficulty(blobs, 2, -1); err != ErrInvalidWorkers {
+		t.Errorf("expected ErrInvalidWorkers, got %v\n", err)
+	}
+	if _, _, err := SumManyWithDifficulty([][]byte{make([]byte, 42)}, 1, 0); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+}
+
+func BenchmarkSumBatch(b *testing.B) {
+	// a batch of 16 blobs per op, over 1, 2, 4 and so on workers up to
+	// GOMAXPROCS; the time per op falls with workers as long as they scale
+	blobs := make([][]byte, 16)
+	for i := range blobs {
+		blobs[i] = make([]byte, 76)
+		blobs[i][39] = byte(i) // the nonce
+	}
+
+	procs := runtime.GOMAXPROCS(0)
+	for workers := 1; ; workers *= 2 {
+		if workers > procs {
+			workers = procs
+		}
+		b.Run(strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SumBatch(blobs, 2, workers)
+			}
+		})
+		if workers == procs {
+			break
+		}
+	}
+}
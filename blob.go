@@ -0,0 +1,97 @@
+package cryptonight
+
+import "encoding/binary"
+
+// NonceOffset is the offset of the 32-bit nonce in a CryptoNote hashing blob
+// whose major and minor versions are 1 byte each and timestamp is 5 bytes as
+// varints, followed by the 32-byte previous block ID. It holds for any block
+// with a timestamp between 2^28 and 2^35-1, which is every block a pool
+// hands out today, but not for the genesis block of Monero: its timestamp is
+// 0, a 1-byte varint, so its nonce is at offset 35.
+const NonceOffset = 39
+
+// MaxBlobSize is the maximal length of data accepted by ValidateBlob. It is far
+// beyond any hashing blob in practice, which is less than 100 bytes for a
+// block of Monero, and only guards against hashing garbage.
+const MaxBlobSize = 64 * 1024
+
+// ValidateBlob checks if blob is acceptable as the input data of variant. It
+// returns ErrUnsupportedVariant, ErrBlobTooShort or ErrBlobTooLong otherwise.
+//
+// A blob that passes ValidateBlob never makes Sum panic.
+func ValidateBlob(blob []byte, variant int) error {
+	if variant < 0 || variant >= len(variants) {
+		return ErrUnsupportedVariant
+	}
+	if len(blob) < variants[variant].minLen {
+		return ErrBlobTooShort
+	}
+	if len(blob) > MaxBlobSize {
+		return ErrBlobTooLong
+	}
+
+	return nil
+}
+
+// TrySum is like Sum, but it validates data with ValidateBlob first, and
+// returns the error instead of panicking.
+func TrySum(data []byte, variant int) ([]byte, error) {
+	if err := ValidateBlob(data, variant); err != nil {
+		return nil, err
+	}
+
+	return Sum(data, variant), nil
+}
+
+// TrySum is like Sum, but it validates data with ValidateBlob first, and
+// returns the error instead of panicking. It does so whether or not cache is
+// created with WithStrictValidation, and is the recommended entry for callers
+// that want errors.
+func (cache *Cache) TrySum(data []byte, variant int) ([]byte, error) {
+	if err := ValidateBlob(data, variant); err != nil {
+		return nil, err
+	}
+
+	return cache.Sum(data, variant), nil
+}
+
+// StripFrame returns the blob inside framed, a blob preceded by its length as
+// a 2-byte little-endian integer, as some transports frame hashing blobs. The
+// result is a subslice of framed, without the 2 bytes, so offsets into it,
+// such as NonceOffset, are those of the blob itself.
+//
+// StripFrame returns ErrInvalidFrame if framed is shorter than 2 bytes, or if
+// the declared length differs from the number of bytes that follow it.
+func StripFrame(framed []byte) ([]byte, error) {
+	if len(framed) < 2 || int(binary.LittleEndian.Uint16(framed)) != len(framed)-2 {
+		return nil, ErrInvalidFrame
+	}
+
+	return framed[2:], nil
+}
+
+// AssembleBlob assembles the hashing blob to hash and submit from the hashing
+// blob template received from a pool and the nonce. It returns a copy of
+// template with nonce written at NonceOffset as a 32-bit little endian
+// integer; template is left untouched.
+//
+// This is the only layout supported: the nonce of a blob as described by
+// NonceOffset, and nothing else. An extra nonce isn't part of the hashing blob.
+// The pool reserves space for it in the miner transaction of the block
+// template, and placing it there changes the merkle root in the hashing blob,
+// so the hashing blob has to be derived anew from the block template, which
+// this package doesn't do.
+//
+// AssembleBlob panics with ErrBlobTooShort if template is too short to hold a
+// nonce at NonceOffset.
+func AssembleBlob(template []byte, nonce uint32) []byte {
+	if len(template) < NonceOffset+4 {
+		panic(ErrBlobTooShort)
+	}
+
+	blob := make([]byte, len(template))
+	copy(blob, template)
+	binary.LittleEndian.PutUint32(blob[NonceOffset:], nonce)
+
+	return blob
+}
@@ -0,0 +1,122 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestValidateBlob(t *testing.T) {
+	specs := []struct {
+		length  int
+		variant int
+		err     error
+	}{
+		{0, 0, nil},
+		{0, 2, nil},
+		{42, 1, ErrBlobTooShort},
+		{43, 1, nil},
+		{76, 1, nil},
+		{MaxBlobSize, 2, nil},
+		{MaxBlobSize + 1, 0, ErrBlobTooLong},
+		{76, -1, ErrUnsupportedVariant},
+		{76, len(variants), ErrUnsupportedVariant},
+	}
+	for i, v := range specs {
+		if err := ValidateBlob(make([]byte, v.length), v.variant); err != v.err {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.err, err)
+		}
+	}
+}
+
+func TestTrySum(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		result, err := TrySum(in, v.variant)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", i, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+
+		if result, err = cache.TrySum(in, v.variant); err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", i, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+
+	if _, err := TrySum([]byte("Obviously less than 43 bytes"), 1); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+	if _, err := cache.TrySum([]byte("Obviously less than 43 bytes"), 1); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+}
+
+func TestAssembleBlob(t *testing.T) {
+	// a Monero hashing blob from tests-slow-1.txt, with its nonce cleared as
+	// the template
+	v := hashSpecsV1[3]
+	in, _ := hex.DecodeString(v.input)
+	template := append([]byte(nil), in...)
+	copy(template[NonceOffset:NonceOffset+4], []byte{0, 0, 0, 0})
+	nonce := binary.LittleEndian.Uint32(in[NonceOffset:])
+
+	blob := AssembleBlob(template, nonce)
+	if !bytes.Equal(blob, in) {
+		t.Fatalf("\nexpected:\n\t%x\ngot:\n\t%x\n", in, blob)
+	}
+	if result := hex.EncodeToString(Sum(blob, v.variant)); result != v.output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", v.output, result)
+	}
+	for _, b := range template[NonceOffset : NonceOffset+4] {
+		if b != 0 {
+			t.Fatal("expected template to be untouched")
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != ErrBlobTooShort {
+			t.Errorf("expected panic with %v, got %v\n", ErrBlobTooShort, r)
+		}
+	}()
+	AssembleBlob(make([]byte, NonceOffset+3), 0)
+}
+
+func TestStripFrame(t *testing.T) {
+	v := hashSpecsV2[0]
+	in, _ := hex.DecodeString(v.input)
+	framed := append([]byte{byte(len(in)), byte(len(in) >> 8)}, in...)
+
+	blob, err := StripFrame(framed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if !bytes.Equal(blob, in) {
+		t.Fatalf("\nexpected:\n\t%x\ngot:\n\t%x\n", in, blob)
+	}
+	if result := hex.EncodeToString(Sum(blob, v.variant)); result != v.output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", v.output, result)
+	}
+	if blob, err := StripFrame([]byte{0, 0}); err != nil || len(blob) != 0 {
+		t.Errorf("expected an empty blob, got %x, %v\n", blob, err)
+	}
+
+	for i, framed := range [][]byte{
+		nil,
+		{0},
+		{1, 0},
+		{0, 0, 0},
+		{1, 1, 0}, // big endian
+		append([]byte{byte(len(in)), byte(len(in) >> 8)}, in[1:]...),
+	} {
+		if _, err := StripFrame(framed); err != ErrInvalidFrame {
+			t.Errorf("[%d] expected ErrInvalidFrame, got %v\n", i, err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"unsafe"
+
+	"ekyu.moe/cryptonight/internal/aes"
+	"ekyu.moe/cryptonight/internal/sha3"
+)
+
+// initCheckpoint returns the keccak state and the first scratchpad block right
+// after scratchpad initialization, through the same code path as Sum.
+//
+// It is meant for bisecting a new port against this package. variant is
+// accepted for symmetry with Sum; the initialization is identical for every
+// supported variant.
+func (cache *Cache) initCheckpoint(data []byte, variant int) ([200]byte, [16]uint64) {
+	cache.scratchpadInit(data, paramsOf(variant))
+
+	var block [16]uint64
+	copy(block[:], cache.scratchpad[:16])
+
+	return *(*[200]byte)(unsafe.Pointer(&cache.finalState[0])), block
+}
+
+func TestInitCheckpoint(t *testing.T) {
+	cache := new(Cache)
+	for _, variant := range []int{0, 1, 2} {
+		in, _ := hex.DecodeString(hashSpecsV1[0].input)
+		state, block := cache.initCheckpoint(in, variant)
+
+		// the first 32 bytes of the state is cn_fast_hash of the input
+		if h := FastHash(in); !bytes.Equal(state[:32], h[:]) {
+			t.Errorf("[%d] state expected to start with:\n\t%x\ngot:\n\t%x\n", variant, h, state[:32])
+		}
+
+		// the first block is the state[64:192] encrypted by the key of
+		// state[:32], with 10 rounds each
+		var (
+			key      [4]uint64
+			expected [16]uint64
+			rkeys    aes.CnKeySchedule
+		)
+		copy(key[:], (*[25]uint64)(unsafe.Pointer(&state[0]))[:4])
+		copy(expected[:], (*[25]uint64)(unsafe.Pointer(&state[0]))[8:24])
+		aes.CnExpandKey(key[:], &rkeys)
+		for j := 0; j < 16; j += 2 {
+			aes.CnRounds(expected[j:], expected[j:], &rkeys)
+		}
+		if block != expected {
+			t.Errorf("[%d] block expected:\n\t%x\ngot:\n\t%x\n", variant, expected, block)
+		}
+	}
+}
+
+func TestScratchpadInitChecksum(t *testing.T) {
+	// cn_fast_hash of the whole 2 MiB scratchpad right after initialization
+	// from "This is a test". Every supported variant shares the one size and
+	// the initialization. The value is computed by this package and agrees
+	// with the byte-level model of CNS008 in cns008_test.go; no published
+	// vector of the scratchpad exists.
+	const expected = "5efd6cbe741bfcb5364bd4d8e7bfd0bbd4bad6c1360c1977daa0bc1b7490c4c7"
+
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	cache := new(Cache)
+	for _, variant := range SupportedVariants() {
+		cache.scratchpadInit(in, paramsOf(variant))
+		sum := FastHash((*[len(cache.scratchpad) * 8]byte)(unsafe.Pointer(&cache.scratchpad[0]))[:])
+		if result := hex.EncodeToString(sum[:]); result != expected {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", variant, expected, result)
+		}
+	}
+}
+
+// runMainLoop runs the memory-hard loop on the current scratchpad, starting
+// from a and b instead of those derived from the keccak state. The rest of the
+// state, including the extra inputs of variant 2, is kept as is. It returns
+// the final a and b.
+//
+// v1Tweak is only used by variant 1, see variant1Tweak.
+func (cache *Cache) runMainLoop(a, b [2]uint64, v1Tweak uint64, variant int) ([2]uint64, [2]uint64) {
+	copy(cache.finalState[:4], []uint64{a[0], a[1], b[0], b[1]})
+	copy(cache.finalState[4:8], make([]uint64, 4))
+	cache.allocScratchpad()
+
+	return cache.memoryHardLoop(paramsOf(variant), v1Tweak)
+}
+
+func TestRunMainLoop(t *testing.T) {
+	// the stages together give the same result as Sum
+	cache := new(Cache)
+	for _, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		state, _ := cache.initCheckpoint(in, v.variant)
+		st := (*[25]uint64)(unsafe.Pointer(&state[0]))
+
+		var v1Tweak uint64
+		if v.variant == 1 {
+			v1Tweak = variant1Tweak(st, in, 35)
+		}
+		a := [2]uint64{st[0] ^ st[4], st[1] ^ st[5]}
+		b := [2]uint64{st[2] ^ st[6], st[3] ^ st[7]}
+		cache.runMainLoop(a, b, v1Tweak, v.variant)
+
+		// result calculation takes the key from state[4:8]
+		copy(cache.finalState[:], st[:])
+		if result := cache.resultCalc(paramsOf(v.variant), nil); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.output, result)
+		}
+	}
+
+	// the loop is deterministic on a known scratchpad, and does modify it
+	var (
+		x, y  = new(Cache), new(Cache)
+		a, b  = [2]uint64{1, 2}, [2]uint64{3, 4}
+		empty [2 * 1024 * 1024 / 8]uint64
+	)
+	for _, variant := range []int{0, 1, 2} {
+		xa, xb := x.runMainLoop(a, b, 5, variant)
+		ya, yb := y.runMainLoop(a, b, 5, variant)
+		if xa != ya || xb != yb || *x.scratchpad != *y.scratchpad {
+			t.Errorf("[%d] expected same results on the same scratchpad\n", variant)
+		}
+		if *x.scratchpad == empty {
+			t.Errorf("[%d] expected the scratchpad to be modified\n", variant)
+		}
+	}
+}
+
+func TestVariant2Seeds(t *testing.T) {
+	// the first iteration of variant 2 uses division and sqrt results seeded
+	// from state[12] and state[13]; variant 0 doesn't read them at all
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	cache := new(Cache)
+
+	firstA := func(variant int, zeroSeeds bool) [2]uint64 {
+		p := variants[variant]
+		cache.scratchpadInit(in, &p)
+		if zeroSeeds {
+			cache.finalState[12], cache.finalState[13] = 0, 0
+		}
+
+		var first [2]uint64
+		p.trace = func(iter int, a, _ [2]uint64) {
+			if iter == 0 {
+				first = a
+			}
+		}
+		cache.memoryHardLoop(&p, 0)
+		return first
+	}
+
+	if seeded, zero := firstA(2, false), firstA(2, true); seeded == zero {
+		t.Errorf("variant 2: the first iteration doesn't depend on the seeds, a = %x\n", seeded)
+	}
+	if seeded, zero := firstA(0, false), firstA(0, true); seeded != zero {
+		t.Errorf("variant 0: expected the seeds to be unused, got %x and %x\n", seeded, zero)
+	}
+}
+
+func TestResultState(t *testing.T) {
+	// cn_fast_hash of the whole keccak state right before the final
+	// permutation, computed by this package; no published vector of it exists
+	cache := new(Cache)
+	for _, v := range []struct {
+		hashSpec
+		state string
+	}{
+		{hashSpecsV0[1], "f304e0c990d7c6dac61848b897edacf55b89e8f13e62a97f592fe294cadbd516"},
+		{hashSpecsV1[0], "8f6ad5f6f145e36f2b55149655cbb71738992e66912184e3d9fee51814c65995"},
+		{hashSpecsV2[0], "88071dc07eca32a8c02d67d209e9b68d992e173db3445eddb36423d08759c585"},
+	} {
+		in, _ := hex.DecodeString(v.input)
+		p := paramsOf(v.variant)
+		cache.scratchpadInit(in, p)
+		var v1Tweak uint64
+		if p.variant1 {
+			v1Tweak = variant1Tweak(&cache.finalState, in, 35)
+		}
+		cache.memoryHardLoop(p, v1Tweak)
+		saved := cache.finalState
+
+		cache.resultState(p)
+		state := (*[200]byte)(unsafe.Pointer(&cache.finalState[0]))
+		if sum := FastHash(state[:]); hex.EncodeToString(sum[:]) != v.state {
+			t.Errorf("\n[%d] state expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.state, sum)
+		}
+
+		// only state[8:24] is overwritten, the key of state[4:8] included
+		for i := range saved {
+			if (i < 8 || i >= 24) && cache.finalState[i] != saved[i] {
+				t.Errorf("[%d] state[%d] expected to stay %#016x, got %#016x\n", v.variant, i, saved[i], cache.finalState[i])
+			}
+		}
+
+		// and the final permutation and hash of it give the digest
+		sha3.Keccak1600Permute(&cache.finalState)
+		h := newFinalHash[p.selectFinal(&cache.finalState)]()
+		h.Write(state[:])
+		if result := hex.EncodeToString(h.Sum(nil)); result != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", v.variant, v.output, result)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+	"unsafe"
+
+	"ekyu.moe/cryptonight/internal/sha3"
+)
+
+// cns008 is a literal, byte-oriented model of the original CryptoNight as
+// written in CNS008, sharing nothing with this package but Keccak and the
+// final hashes. Its AES is derived from the definitions, S-box included,
+// rather than from tables, and it works on bytes rather than words.
+type cns008 struct {
+	sbox       [256]byte
+	state      [200]byte
+	scratchpad [2 * 1024 * 1024]byte
+	a, b       [16]byte
+}
+
+func newCNS008() *cns008 {
+	m := new(cns008)
+
+	// multiplicative inverse in GF(2^8) followed by the affine transformation
+	mul := func(x, y byte) byte {
+		var p byte
+		for ; y != 0; y >>= 1 {
+			if y&1 != 0 {
+				p ^= x
+			}
+			x = x<<1 ^ byte(int8(x)>>7)&0x1b
+		}
+		return p
+	}
+	rotl := func(x byte, n uint) byte { return x<<n | x>>(8-n) }
+	for i := 0; i < 256; i++ {
+		var inv byte
+		for j := 1; j < 256 && i != 0; j++ {
+			if mul(byte(i), byte(j)) == 1 {
+				inv = byte(j)
+				break
+			}
+		}
+		m.sbox[i] = inv ^ rotl(inv, 1) ^ rotl(inv, 2) ^ rotl(inv, 3) ^ rotl(inv, 4) ^ 0x63
+	}
+
+	return m
+}
+
+// expandKey returns the first 10 round keys of the AES-256 key schedule.
+func (m *cns008) expandKey(key []byte) [10][16]byte {
+	var w [40][4]byte
+	for i := 0; i < 8; i++ {
+		copy(w[i][:], key[4*i:])
+	}
+	rcon := byte(1)
+	for i := 8; i < 40; i++ {
+		t := w[i-1]
+		switch i % 8 {
+		case 0:
+			t = [4]byte{m.sbox[t[1]] ^ rcon, m.sbox[t[2]], m.sbox[t[3]], m.sbox[t[0]]}
+			rcon = rcon<<1 ^ byte(int8(rcon)>>7)&0x1b
+		case 4:
+			t = [4]byte{m.sbox[t[0]], m.sbox[t[1]], m.sbox[t[2]], m.sbox[t[3]]}
+		}
+		for j := range t {
+			w[i][j] = w[i-8][j] ^ t[j]
+		}
+	}
+
+	var keys [10][16]byte
+	for i := range keys {
+		for j := 0; j < 4; j++ {
+			copy(keys[i][4*j:], w[4*i+j][:])
+		}
+	}
+	return keys
+}
+
+// aesRound is SubBytes, ShiftRows, MixColumns and AddRoundKey, with the state
+// in column-major order.
+func (m *cns008) aesRound(block []byte, key []byte) {
+	var s [16]byte
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			s[r+4*c] = m.sbox[block[r+4*((c+r)%4)]]
+		}
+	}
+	xtime := func(x byte) byte { return x<<1 ^ byte(int8(x)>>7)&0x1b }
+	for c := 0; c < 4; c++ {
+		col := s[4*c : 4*c+4]
+		a0, a1, a2, a3 := col[0], col[1], col[2], col[3]
+		col[0] = xtime(a0) ^ xtime(a1) ^ a1 ^ a2 ^ a3
+		col[1] = a0 ^ xtime(a1) ^ xtime(a2) ^ a2 ^ a3
+		col[2] = a0 ^ a1 ^ xtime(a2) ^ xtime(a3) ^ a3
+		col[3] = xtime(a0) ^ a0 ^ a1 ^ a2 ^ xtime(a3)
+	}
+	for i := range s {
+		block[i] = s[i] ^ key[i]
+	}
+}
+
+// init is CNS008 sec.3 Scratchpad Initialization.
+func (m *cns008) init(data []byte) {
+	var st [25]uint64
+	sha3.Keccak1600State(&st, data)
+	for i, v := range st {
+		binary.LittleEndian.PutUint64(m.state[8*i:], v)
+	}
+
+	keys := m.expandKey(m.state[:32])
+	var blocks [128]byte
+	copy(blocks[:], m.state[64:192])
+	for off := 0; off < len(m.scratchpad); off += 128 {
+		for i := 0; i < 128; i += 16 {
+			for _, key := range keys {
+				m.aesRound(blocks[i:i+16], key[:])
+			}
+		}
+		copy(m.scratchpad[off:], blocks[:])
+	}
+}
+
+// loop is CNS008 sec.4 Memory-Hard Loop.
+func (m *cns008) loop() {
+	for i := 0; i < 16; i++ {
+		m.a[i] = m.state[i] ^ m.state[32+i]
+		m.b[i] = m.state[16+i] ^ m.state[48+i]
+	}
+	addr := func(x [16]byte) []byte {
+		off := binary.LittleEndian.Uint64(x[:]) & 0x1ffff0
+		return m.scratchpad[off : off+16]
+	}
+
+	for i := 0; i < 524288; i++ {
+		// the first half
+		p := addr(m.a)
+		m.aesRound(p, m.a[:])
+		var c [16]byte
+		copy(c[:], p)
+		for j := range p {
+			p[j] = m.b[j] ^ c[j]
+		}
+		m.b = c
+
+		// the second half, 8byte_mul and 8byte_add
+		p = addr(m.b)
+		x, y := binary.LittleEndian.Uint64(m.b[:]), binary.LittleEndian.Uint64(p)
+		hi, lo := m.mul(x, y)
+		binary.LittleEndian.PutUint64(m.a[:], binary.LittleEndian.Uint64(m.a[:])+hi)
+		binary.LittleEndian.PutUint64(m.a[8:], binary.LittleEndian.Uint64(m.a[8:])+lo)
+		var d [16]byte
+		copy(d[:], p)
+		copy(p, m.a[:])
+		for j := range m.a {
+			m.a[j] ^= d[j]
+		}
+	}
+}
+
+// result is CNS008 sec.5 Result Calculation.
+func (m *cns008) result() []byte {
+	keys := m.expandKey(m.state[32:64])
+	var blocks [128]byte
+	copy(blocks[:], m.state[64:192])
+	for off := 0; off < len(m.scratchpad); off += 128 {
+		for i := 0; i < 128; i++ {
+			blocks[i] ^= m.scratchpad[off+i]
+		}
+		for i := 0; i < 128; i += 16 {
+			for _, key := range keys {
+				m.aesRound(blocks[i:i+16], key[:])
+			}
+		}
+	}
+	copy(m.state[64:192], blocks[:])
+
+	var st [25]uint64
+	for i := range st {
+		st[i] = binary.LittleEndian.Uint64(m.state[8*i:])
+	}
+	sha3.Keccak1600Permute(&st)
+	for i, v := range st {
+		binary.LittleEndian.PutUint64(m.state[8*i:], v)
+	}
+
+	h := newFinalHash[m.state[0]&0x03]()
+	h.Write(m.state[:])
+	return h.Sum(nil)
+}
+
+// mul is the full 128-bit product of x and y, by 32-bit halves.
+func (m *cns008) mul(x, y uint64) (hi, lo uint64) {
+	x0, x1 := x&0xffffffff, x>>32
+	y0, y1 := y&0xffffffff, y>>32
+	w0 := x0 * y0
+	t := x1*y0 + w0>>32
+	w1, w2 := t&0xffffffff, t>>32
+	w1 += x0 * y1
+	return x1*y1 + w2 + w1>>32, x * y
+}
+
+func TestCNS008(t *testing.T) {
+	m := newCNS008()
+
+	// the test vectors of CNS008 itself
+	for i, v := range hashSpecsV0[:2] {
+		in, _ := hex.DecodeString(v.input)
+		cache := new(Cache)
+		p := paramsOf(0)
+
+		m.init(in)
+		cache.scratchpadInit(in, p)
+		if !bytes.Equal((*[200]byte)(unsafe.Pointer(&cache.finalState[0]))[:], m.state[:]) {
+			t.Fatalf("[%d] keccak state differs from CNS008 sec.3\n", i)
+		}
+		if !bytes.Equal((*[2 * 1024 * 1024]byte)(unsafe.Pointer(&cache.scratchpad[0]))[:], m.scratchpad[:]) {
+			t.Fatalf("[%d] scratchpad differs from CNS008 sec.3 after initialization\n", i)
+		}
+
+		m.loop()
+		a, b := cache.memoryHardLoop(p, 0)
+		if !bytes.Equal((*[2 * 1024 * 1024]byte)(unsafe.Pointer(&cache.scratchpad[0]))[:], m.scratchpad[:]) {
+			t.Fatalf("[%d] scratchpad differs from CNS008 sec.4 after the memory-hard loop\n", i)
+		}
+		if *(*[16]byte)(unsafe.Pointer(&a)) != m.a || *(*[16]byte)(unsafe.Pointer(&b)) != m.b {
+			t.Errorf("[%d] a and b differ from CNS008 sec.4:\n\t%x %x\ngot:\n\t%x %x\n", i, m.a, m.b, a, b)
+		}
+
+		expected := m.result()
+		if hex.EncodeToString(expected) != v.output {
+			t.Fatalf("\n[%d] the model of CNS008 itself is wrong, expected:\n\t%s\ngot:\n\t%x\n", i, v.output, expected)
+		}
+		if result := cache.resultCalc(p, nil); !bytes.Equal(result, expected) {
+			t.Errorf("\n[%d] result calculation differs from CNS008 sec.5, expected:\n\t%x\ngot:\n\t%x\n", i, expected, result)
+		}
+	}
+}
+
+func TestResultCalcChaining(t *testing.T) {
+	// result calculation XORs each 128-byte chunk of the scratchpad into the
+	// blocks encrypted from the previous one, which resultCalc reads back
+	// from the scratchpad itself, in place; compare it with the model on
+	// arbitrary scratchpads, beyond the few that a whole Sum can produce
+	m := newCNS008()
+	cache := new(Cache)
+	cache.allocScratchpad()
+	p := paramsOf(0)
+
+	x := uint64(0x9e3779b97f4a7c15)
+	next := func() uint64 {
+		x = x*6364136223846793005 + 1442695040888963407
+		return x
+	}
+
+	var prev []byte
+	for i, fill := range []func(j int) uint64{
+		func(int) uint64 { return next() },
+		func(j int) uint64 { return uint64(j % 16) }, // every chunk the same
+		func(int) uint64 { return 0 },
+		func(j int) uint64 { // the same as the above but the first chunk
+			if j == 0 {
+				return 1
+			}
+			return 0
+		},
+	} {
+		cache.finalState = [25]uint64{}
+		if i < 2 {
+			for j := range cache.finalState {
+				cache.finalState[j] = next()
+			}
+		}
+		for j := range cache.scratchpad {
+			cache.scratchpad[j] = fill(j)
+		}
+		copy(m.state[:], (*[200]byte)(unsafe.Pointer(&cache.finalState[0]))[:])
+		copy(m.scratchpad[:], (*[2 * 1024 * 1024]byte)(unsafe.Pointer(&cache.scratchpad[0]))[:])
+
+		expected := m.result()
+		if result := cache.resultCalc(p, nil); !bytes.Equal(result, expected) {
+			t.Errorf("\n[%d] result calculation differs from CNS008 sec.5, expected:\n\t%x\ngot:\n\t%x\n", i, expected, result)
+		}
+
+		// a change in the first chunk reaches the end through every chunk
+		if i == 3 && bytes.Equal(expected, prev) {
+			t.Errorf("[%d] expected the first chunk to change the digest\n", i)
+		}
+		prev = expected
+	}
+}
+
+func TestSumLargeInput(t *testing.T) {
+	x := uint64(0x9e3779b97f4a7c15)
+	in := make([]byte, MaxBlobSize+137)
+	for i := range in {
+		x = x*6364136223846793005 + 1442695040888963407
+		in[i] = byte(x >> 56)
+	}
+
+	for _, n := range []int{135, 136, 137, 4096, MaxBlobSize, len(in)} {
+		// Keccak absorbs it all, the same as the streaming sponge
+		h := sha3.NewLegacyKeccak256()
+		for off := 0; off < n; off += 1000 {
+			end := off + 1000
+			if end > n {
+				end = n
+			}
+			h.Write(in[off:end])
+		}
+		if sum := FastHash(in[:n]); !bytes.Equal(sum[:], h.Sum(nil)) {
+			t.Errorf("%d bytes: expected:\n\t%x\ngot:\n\t%x\n", n, h.Sum(nil), sum)
+		}
+	}
+
+	// the whole hash, against the model of CNS008
+	m := newCNS008()
+	m.init(in)
+	m.loop()
+	expected := m.result()
+	if result := Sum(in, 0); !bytes.Equal(result, expected) {
+		t.Errorf("\nexpected:\n\t%x\ngot:\n\t%x\n", expected, result)
+	}
+
+	// and variant 1 reads nothing but in[35:43] for its tweak
+	cache := new(Cache)
+	p := paramsOf(1)
+	cache.scratchpadInit(in, p)
+	cache.memoryHardLoop(p, variant1Tweak(&cache.finalState, in[:43], 35))
+	if result, expected := cache.resultCalc(p, nil), Sum(in, 1); !bytes.Equal(result, expected) {
+		t.Errorf("\nexpected:\n\t%x\ngot:\n\t%x\n", expected, result)
+	}
+}
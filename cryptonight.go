@@ -6,157 +6,433 @@ package cryptonight // import "ekyu.moe/cryptonight"
 import (
 	"encoding/binary"
 	"hash"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"ekyu.moe/cryptonight/internal/aes"
 	"ekyu.moe/cryptonight/internal/sha3"
 )
 
+const (
+	// scratchpadSize is the size of the scratchpad in bytes.
+	scratchpadSize = 2 * 1024 * 1024
+
+	// addrMask is TO_ADDR of CNS008 for scratchpadSize: it masks a word of a
+	// or c into the offset of a 16-byte block within the scratchpad, which is
+	// 0x1ffff0 for 2 MiB. scratchpadSize must be a power of 2.
+	addrMask = scratchpadSize - 16
+
+	// iterations is the number of iterations of the memory-hard loop, the
+	// same for every supported variant.
+	iterations = 524288
+)
+
 // Sum calculate a CryptoNight hash digest. The return value is exactly 32 bytes
 // long.
 //
+// data may be empty or nil, in which case the Keccak state of the empty message
+// drives the scratchpad, as CNS008 defines. data needs no particular alignment;
+// absorbing it takes about a microsecond either way, next to milliseconds for
+// the whole hash.
+//
+// data has no maximal length either: Keccak absorbs it 136 bytes at a time in
+// place, and nothing else reads data, except for variant 1, whose tweak is
+// data[35:43] whatever the length. Only ValidateBlob and the strict Cache
+// reject data longer than MaxBlobSize, as a guard against hashing garbage.
+//
+// Variant 0 is the original algorithm of CNS008, identical to monerod's
+// cn_slow_hash with variant 0, which verifies every Monero block before the
+// variant 1 fork, back to genesis. Bytecoin uses the same function; there is
+// no earlier interpretation needing a separate legacy variant.
+//
+// Sum panics if variant is not one of SupportedVariants, instead of computing
+// a digest of some other variant. None of the supported variants depend on the
+// block height, so Sum takes none.
+//
 // When variant is 1, data is required to have at least 43 bytes.
 // This is assumed and not checked by Sum. If this condition doesn't meet, Sum
-// will panic straightforward.
+// will panic straightforward, which is also the case for empty data.
 func Sum(data []byte, variant int) []byte {
-	cc := cachePool.Get().(*cache)
-	sum := cc.sum(data, variant)
-	cachePool.Put(cc)
+	cache := cachePool.Get().(*Cache)
+	sum := cache.Sum(data, variant)
+	cachePool.Put(cache)
 
 	return sum
 }
 
-type cache struct {
+// SumLight is like Sum, but it allocates a fresh Cache for the calculation and
+// leaves it to the GC afterwards, instead of keeping it in the internal pool.
+//
+// SumLight is meant for callers that only verify a hash occasionally, such as
+// a light wallet checking a single PoW per block, and don't want to hold on to
+// the memory between calls. For repeated hashing, use Sum or a dedicated Cache.
+func SumLight(data []byte, variant int) []byte {
+	return new(Cache).Sum(data, variant)
+}
+
+// SumAll calculates the CryptoNight hash digest of data under every supported
+// variant, keyed by variant. Variants that data is too short for, such as
+// variant 1 for data shorter than 43 bytes, are left out.
+//
+// SumAll is meant for debugging and discovering which variant some data is
+// hashed with; it is several times slower than a single Sum.
+func SumAll(data []byte) map[int][]byte {
+	cache := cachePool.Get().(*Cache)
+	sums := make(map[int][]byte, len(variants))
+	for i := range variants {
+		if len(data) < variants[i].minLen {
+			continue
+		}
+		sums[i] = cache.sum(data, &variants[i])
+	}
+	cachePool.Put(cache)
+
+	return sums
+}
+
+// Backend returns the name of the AES implementation in use, "aes-ni" or "go".
+// It is picked once at init by probing the CPU, so a single binary runs with
+// the fastest path available on each machine.
+func Backend() string {
+	return aes.Backend()
+}
+
+// BuildInfo returns a one-line, human-readable summary of how this package is
+// built and runs on this machine: the AES backend, the square root of variant
+// 2, the supported variants, and the Go version and platform. It is meant to
+// be logged at startup, so that a report of mismatching hashes comes with the
+// facts needed to triage it. The format may change and is not for parsing.
+func BuildInfo() string {
+	names := make([]string, len(variants))
+	for i := range variants {
+		names[i] = variants[i].name
+	}
+
+	return "cryptonight: aes=" + Backend() +
+		" sqrt=" + sqrtImpl +
+		" variants=" + strings.Join(names, ",") +
+		" " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Cache can reduce GC pressure by reusing the memory CryptoNight needs. The zero
+// value of Cache is ready to use.
+//
+// A Cache is not concurrent safe: it must not be used by more than one Sum at
+// a time. Use one Cache per goroutine, or let the top-level Sum manage them.
+// Sum panics when it detects such misuse, instead of silently producing
+// a corrupted result.
+//
+// A Cache is not tied to any variant. Every Sum initializes all the state it
+// reads, so one Cache can hash different variants in turn, such as for a
+// worker mining several coins, and gives the same digests a dedicated Cache
+// per variant would.
+//
+// The scratchpad is allocated apart from the rest of Cache, on the first Sum
+// unless it comes from a ScratchpadAllocator. On the heap, it is a large object
+// to the Go runtime and thus starts on a page boundary, so it is at least
+// 64-byte (cache line) aligned, even for a Cache embedded in another struct.
+// Together, a Cache holds CacheSize bytes.
+type Cache struct {
 	// DO NOT change the order of these fields in this struct!
 	// They are carefully placed in this order to keep at least 64-bit aligned
 	// for some fields.
 	//
 	// In the future the alignment may be set explicitly, see
 	// https://github.com/golang/go/issues/19057
+	//
+	// Every unsafe.Pointer conversion in this package reinterprets memory
+	// inside a single array of Cache, such as the 64-byte block of scratchpad
+	// for the variant 2 shuffle and finalState as 200 bytes for the final
+	// hash. The *Cache in scope keeps that memory alive, the scratchpad through
+	// its pointer, so no runtime.KeepAlive is needed, as long as the converted
+	// type never reaches past the end of its array and the pointer never
+	// outlives a call.
+	// Temporaries that are converted this way must stay fields of Cache, not
+	// locals; TestUnsafeAliasing exercises this under the race detector.
+
+	finalState [25]uint64 // state of keccak1600
+
+	blocks [16]uint64        // temporary chunk/pointer of data
+	rkeys  aes.CnKeySchedule // 10 rounds, instead of 14 as in standard AES-256
+
+	running uint32 // non-zero while a Sum is in progress, accessed atomically
+
+	finalHashes [4]hash.Hash // lazily created instances of the final hashes
+	lastFinal   FinalHash    // the final hash selected by the last Sum
+	digest      [64]byte     // output of the final hash, before appending
 
-	scratchpad [2 * 1024 * 1024 / 8]uint64 // 2 MiB scratchpad for memhard loop
-	finalState [25]uint64                  // state of keccak1600
+	strict bool // validate the input of Sum, see WithStrictValidation
 
-	blocks [16]uint64 // temporary chunk/pointer of data
-	rkeys  [40]uint32 // 10 rounds, instead of 14 as in standard AES-256
+	iterations iterationCount // of the memory-hard loop by the last Sum, debug only
+
+	scratchpad *[scratchpadSize / 8]uint64 // 2 MiB scratchpad for memhard loop
+	alloc      ScratchpadAllocator         // allocator of scratchpad, nil for the heap
 }
 
-func (cc *cache) sum(data []byte, variant int) []byte {
-	//////////////////////////////////////////////////
-	// these variables never escape to heap
-	var (
-		// used in memory hard
-		addr    uint64
-		a, c, d [2]uint64
-		b       [4]uint64 // variant 2 needs [4]uint64
+// Sum calculate a CryptoNight hash digest, using cache as its working memory.
+// It is otherwise identical to the top-level Sum, unless cache is created with
+// WithStrictValidation.
+func (cache *Cache) Sum(data []byte, variant int) []byte {
+	if cache.strict {
+		if err := ValidateBlob(data, variant); err != nil {
+			panic(err)
+		}
+	}
 
-		// for variant 1
-		v1Tweak, v1Tmp uint64
+	return cache.sum(data, paramsOf(variant))
+}
 
-		// for variant 2
-		offset0, offset1, offset2 uint64
-		tmpChunk                  [2]uint64
-		divisor, divisionResult   uint64
-		sqrtInput, sqrtResult     uint64
-	)
+// SumFromState is like Sum, but it starts from state as the keccak state of
+// the input, instead of calculating it from the data. state is not modified.
+//
+// SumFromState is an advanced hook for building experimental chained PoW. It
+// is not part of any standard coin's consensus, and the caller is responsible
+// for producing a valid state. Variant 1 is not supported, as its tweak needs
+// the data itself; SumFromState panics when variant is 1.
+func (cache *Cache) SumFromState(state *[25]uint64, variant int) []byte {
+	p := paramsOf(variant)
+	if p.variant1 {
+		panic("cryptonight: SumFromState does not support variant 1")
+	}
+
+	cache.acquire()
+	defer cache.release()
 
-	//////////////////////////////////////////////////
-	// as per CNS008 sec.3 Scratchpad Initialization
-	sha3.Keccak1600State(&cc.finalState, data)
+	cache.finalState = *state
+	cache.explode(p)
+	cache.memoryHardLoop(p, 0)
 
-	if variant == 1 {
-		// that's why data must have more than 43 bytes
-		v1Tweak = cc.finalState[24] ^ binary.LittleEndian.Uint64(data[35:43])
+	return cache.resultCalc(p, nil)
+}
+
+// SumChained calculates CryptoNight rounds times in a row using cache, each
+// round hashing the 32-byte digest of the round before it, and returns the
+// digest of the last round. The first round hashes data, so a rounds of 2 is
+// Sum(Sum(data, variant), variant), as composite PoW that run cn(cn(blob)) do.
+// Every round uses variant, and the digest is rewritten in place, so all the
+// rounds together allocate no more than a single Sum.
+//
+// rounds must be at least 1, otherwise SumChained panics. Variant 1 can only
+// run a single round, as it needs at least 43 bytes of input; SumChained
+// panics with ErrBlobTooShort before hashing when rounds is more than 1.
+func (cache *Cache) SumChained(data []byte, variant int, rounds int) []byte {
+	p := paramsOf(variant)
+	if rounds < 1 {
+		panic("cryptonight: SumChained needs at least 1 round")
+	}
+	if rounds > 1 && p.minLen > 32 {
+		panic(ErrBlobTooShort)
 	}
 
-	// scratchpad init
-	aes.CnExpandKey(cc.finalState[:4], &cc.rkeys)
-	copy(cc.blocks[:], cc.finalState[8:24])
+	sum := cache.sum(data, p)
+	for i := 1; i < rounds; i++ {
+		// the input is consumed before the digest is written
+		sum = cache.sumAppend(sum[:0], sum, p)
+	}
 
-	for i := 0; i < 2*1024*1024/8; i += 16 {
-		for j := 0; j < 16; j += 2 {
-			aes.CnRounds(cc.blocks[j:], cc.blocks[j:], &cc.rkeys)
-		}
-		copy(cc.scratchpad[i:], cc.blocks[:])
+	return sum
+}
+
+// acquire marks cache as in use, and panics if it already is.
+func (cache *Cache) acquire() {
+	if !atomic.CompareAndSwapUint32(&cache.running, 0, 1) {
+		panic("cryptonight: concurrent Sum on a single Cache")
 	}
+}
 
-	//////////////////////////////////////////////////
-	// as per CNS008 sec.4 Memory-Hard Loop
-	a[0] = cc.finalState[0] ^ cc.finalState[4]
-	a[1] = cc.finalState[1] ^ cc.finalState[5]
-	b[0] = cc.finalState[2] ^ cc.finalState[6]
-	b[1] = cc.finalState[3] ^ cc.finalState[7]
-	if variant == 2 {
-		b[2] = cc.finalState[8] ^ cc.finalState[10]
-		b[3] = cc.finalState[9] ^ cc.finalState[11]
-		divisionResult = cc.finalState[12]
-		sqrtResult = cc.finalState[13]
+// release marks cache as no longer in use.
+func (cache *Cache) release() {
+	atomic.StoreUint32(&cache.running, 0)
+}
+
+// SumInto is like Sum, but it writes the digest into the first 32 bytes of dst
+// instead of returning it. It returns io.ErrShortBuffer and writes nothing if
+// dst is shorter than 32 bytes.
+func (cache *Cache) SumInto(dst, data []byte, variant int) error {
+	if len(dst) < 32 {
+		return io.ErrShortBuffer
 	}
 
-	for i := 0; i < 524288; i++ {
-		addr = (a[0] & 0x1ffff0) >> 3
-		aes.CnSingleRound(c[:], cc.scratchpad[addr:], &a)
+	cache.sumAppend(dst[:0], data, paramsOf(variant))
+	return nil
+}
 
-		if variant == 2 {
-			// since we use []uint64 instead of []uint8 as scratchpad, the offset applies too
-			offset0 = addr ^ 0x02
-			offset1 = addr ^ 0x04
-			offset2 = addr ^ 0x06
+// SumWithBuffer is like Sum, but it writes the digest into dst, and returns
+// dst[:32]. If dst has a capacity less than 32, a new slice is allocated and
+// returned instead, as append does.
+//
+// When dst has the capacity, SumWithBuffer makes no heap allocation at all,
+// as every temporary of the calculation lives in cache. Together with a Cache
+// per worker, it is the entry for servers that must not allocate per request.
+func (cache *Cache) SumWithBuffer(dst, data []byte, variant int) []byte {
+	return cache.sumAppend(dst[:0], data, paramsOf(variant))
+}
+
+func (cache *Cache) sum(data []byte, p *variantParams) []byte {
+	return cache.sumAppend(nil, data, p)
+}
+
+// sumAppend is sum, but appends the digest to b.
+func (cache *Cache) sumAppend(b, data []byte, p *variantParams) []byte {
+	cache.acquire()
+	defer cache.release()
+
+	cache.scratchpadInit(data, p)
+
+	return cache.sumInitialized(b, data, p)
+}
+
+// sumInitialized finishes the hash of data whose keccak state and scratchpad
+// are already initialized in cache, and appends the digest to b.
+func (cache *Cache) sumInitialized(b, data []byte, p *variantParams) []byte {
+	var v1Tweak uint64
+	if p.variant1 {
+		v1Tweak = variant1Tweak(&cache.finalState, data, p.tweakOffset)
+	}
 
-			tmpChunk[0] = cc.scratchpad[offset0]
-			tmpChunk[1] = cc.scratchpad[offset0+1]
+	cache.memoryHardLoop(p, v1Tweak)
 
-			cc.scratchpad[offset0] = cc.scratchpad[offset2] + b[2]
-			cc.scratchpad[offset0+1] = cc.scratchpad[offset2+1] + b[3]
+	return cache.resultCalc(p, b)
+}
 
-			cc.scratchpad[offset2] = cc.scratchpad[offset1] + a[0]
-			cc.scratchpad[offset2+1] = cc.scratchpad[offset1+1] + a[1]
+// scratchpadInit fills the keccak state of data and the scratchpad, as per
+// CNS008 sec.3 Scratchpad Initialization.
+func (cache *Cache) scratchpadInit(data []byte, p *variantParams) {
+	if p.keccakRate != 0 {
+		sha3.Keccak1600StateRate(&cache.finalState, data, p.keccakRate)
+	} else {
+		sha3.Keccak1600State(&cache.finalState, data)
+	}
+	cache.explode(p)
+}
 
-			cc.scratchpad[offset1] = tmpChunk[0] + b[0]
-			cc.scratchpad[offset1+1] = tmpChunk[1] + b[1]
+// explode fills the scratchpad from the keccak state by AES encryption.
+//
+// The round keys are expanded afresh for every hash, here and in resultCalc.
+// They come from the keccak state, which differs entirely as soon as any byte
+// of the input does, such as the nonce, so no variant has an expansion to
+// reuse across hashes. Each takes about 50 ns with AES-NI, see
+// BenchmarkExpandKey, which is a few millionths of a hash anyway.
+//
+// Encrypting the blocks two at a time, with the table lookups of both
+// interleaved, was tried for the Go AES, and BenchmarkScratchpadInit measured
+// it no faster than one at a time, so the blocks go one by one.
+func (cache *Cache) explode(p *variantParams) {
+	cache.allocScratchpad()
+	p.expandKey(cache.finalState[:4], &cache.rkeys)
+	copy(cache.blocks[:], cache.finalState[8:24])
+
+	for i := 0; i < scratchpadSize/8; i += 16 {
+		for j := 0; j < 16; j += 2 {
+			p.rounds(cache.blocks[j:], cache.blocks[j:], &cache.rkeys)
 		}
+		copy(cache.scratchpad[i:], cache.blocks[:])
+	}
+}
 
-		cc.scratchpad[addr] = b[0] ^ c[0]
-		cc.scratchpad[addr+1] = b[1] ^ c[1]
+// memoryHardLoop runs the memory-hard loop on the scratchpad, as per CNS008
+// sec.4 Memory-Hard Loop, and returns the final a and b.
+//
+// Built with -tags cryptonight_debug, every address is checked to be within
+// the scratchpad, see checkScratchpadAddr.
+//
+// a and b start from the keccak state, as do the extra inputs of variant 2.
+//
+// There is no prefetch of the next block, not even a dummy load where the
+// architecture has no prefetch instruction. The address of each access is
+// only known from the step right before it, so a prefetch can't be issued any
+// earlier than the access itself. A dummy load of the next block at the end
+// of every iteration measured the same as none on amd64, within the noise of
+// BenchmarkCore.
+func (cache *Cache) memoryHardLoop(p *variantParams, v1Tweak uint64) ([2]uint64, [2]uint64) {
+	// these variables never escape to heap
+	var (
+		// used in memory hard
+		addr    uint64
+		a, c, d [2]uint64
+		b       [4]uint64 // variant 2 needs [4]uint64
+
+		// for variant 1
+		v1Tmp uint64
+
+		// for variant 2
+		divisionResult        uint64
+		sqrtInput, sqrtResult uint64
+
+		v1, v2 = p.variant1, p.variant2
+		xtl    = p.xtl
+		trace  = p.trace
+		sp     = cache.scratchpad
+
+		// the steps of variant 2, which are all on unless researching
+		shuffle  = v2 && !p.noShuffle
+		division = v2 && !p.noDivision
+		sqrt     = v2 && !p.noSqrt
+	)
 
-		if variant == 1 {
-			v1Tmp = cc.scratchpad[addr+1] >> 24
-			v1Tmp = ((^v1Tmp)&1)<<4 | (((^v1Tmp)&1)<<4&v1Tmp)<<1 | (v1Tmp&32)>>1
-			cc.scratchpad[addr+1] ^= v1Tmp << 24
+	a[0] = cache.finalState[0] ^ cache.finalState[4]
+	a[1] = cache.finalState[1] ^ cache.finalState[5]
+	b[0] = cache.finalState[2] ^ cache.finalState[6]
+	b[1] = cache.finalState[3] ^ cache.finalState[7]
+	if v2 {
+		// VARIANT2_INIT64: the results of division and sqrt are seeded from
+		// the keccak state, not zero, and the first iteration mixes these
+		// seeds into d before computing its own
+		b[2] = cache.finalState[8] ^ cache.finalState[10]
+		b[3] = cache.finalState[9] ^ cache.finalState[11]
+		divisionResult = cache.finalState[12]
+		sqrtResult = cache.finalState[13]
+	}
+
+	i := 0
+	for ; i < iterations; i++ {
+		addr = (a[0] & addrMask) >> 3
+		checkScratchpadAddr(p, addr)
+		p.singleRound(c[:], sp[addr:], &a)
+
+		if shuffle {
+			variant2Shuffle((*[8]uint64)(unsafe.Pointer(&sp[addr&^7])), addr&7, &a, &b)
+		}
+
+		sp[addr] = b[0] ^ c[0]
+		sp[addr+1] = b[1] ^ c[1]
+
+		if v1 {
+			if xtl {
+				v1Tmp = variantXTLMask(sp[addr+1] >> 24)
+			} else {
+				v1Tmp = variant1Mask(sp[addr+1] >> 24)
+			}
+			sp[addr+1] ^= v1Tmp << 24
 		}
 
-		addr = (c[0] & 0x1ffff0) >> 3
-		d[0] = cc.scratchpad[addr]
-		d[1] = cc.scratchpad[addr+1]
+		addr = (c[0] & addrMask) >> 3
+		checkScratchpadAddr(p, addr)
+		d[0] = sp[addr]
+		d[1] = sp[addr+1]
 
-		if variant == 2 {
+		if v2 {
 			// equivalent to VARIANT2_PORTABLE_INTEGER_MATH in slow-hash.c
 			// VARIANT2_INTEGER_MATH_DIVISION_STEP
 			d[0] ^= divisionResult ^ (sqrtResult << 32)
-			divisor = (c[0]+(sqrtResult<<1))&0xffffffff | 0x80000001
-			divisionResult = (c[1]/divisor)&0xffffffff | (c[1]%divisor)<<32
-			sqrtInput = c[0] + divisionResult
+			if division {
+				divisionResult = v2Division(c[0], c[1], sqrtResult)
+			}
 
 			// VARIANT2_INTEGER_MATH_SQRT_STEP_FP64 and
 			// VARIANT2_INTEGER_MATH_SQRT_FIXUP
-			sqrtResult = v2Sqrt(sqrtInput)
+			if sqrt {
+				sqrtInput = c[0] + divisionResult
+				sqrtResult = v2Sqrt(sqrtInput)
+			}
 
 			// shuffle again, it's the same process as above
-			offset0 = addr ^ 0x02
-			offset1 = addr ^ 0x04
-			offset2 = addr ^ 0x06
-
-			tmpChunk[0] = cc.scratchpad[offset0]
-			tmpChunk[1] = cc.scratchpad[offset0+1]
-
-			cc.scratchpad[offset0] = cc.scratchpad[offset2] + b[2]
-			cc.scratchpad[offset0+1] = cc.scratchpad[offset2+1] + b[3]
-
-			cc.scratchpad[offset2] = cc.scratchpad[offset1] + a[0]
-			cc.scratchpad[offset2+1] = cc.scratchpad[offset1+1] + a[1]
-
-			cc.scratchpad[offset1] = tmpChunk[0] + b[0]
-			cc.scratchpad[offset1+1] = tmpChunk[1] + b[1]
+			if shuffle {
+				variant2Shuffle((*[8]uint64)(unsafe.Pointer(&sp[addr&^7])), addr&7, &a, &b)
+			}
 
 			// re-asign higher-order of  b
 			b[2] = b[0]
@@ -166,11 +442,11 @@ func (cc *cache) sum(data []byte, variant int) []byte {
 		// byteAdd and byteMul altogether
 		byteAddMul(&a, c[0], d[0])
 
-		cc.scratchpad[addr] = a[0]
-		cc.scratchpad[addr+1] = a[1]
+		sp[addr] = a[0]
+		sp[addr+1] = a[1]
 
-		if variant == 1 {
-			cc.scratchpad[addr+1] ^= v1Tweak
+		if v1 {
+			sp[addr+1] ^= v1Tweak
 		}
 
 		a[0] ^= d[0]
@@ -178,32 +454,91 @@ func (cc *cache) sum(data []byte, variant int) []byte {
 
 		b[0] = c[0]
 		b[1] = c[1]
+
+		if trace != nil {
+			trace(i, a, c)
+		}
+	}
+	cache.iterations.record(i)
+
+	return a, [2]uint64{b[0], b[1]}
+}
+
+// resultCalc turns the scratchpad and the keccak state into the final hash
+// digest and appends it to b, as per CNS008 sec.5 Result Calculation.
+func (cache *Cache) resultCalc(p *variantParams, b []byte) []byte {
+	cache.resultState(p)
+	sha3.Keccak1600Permute(&cache.finalState)
+
+	// the final hash
+	final := p.selectFinal(&cache.finalState)
+	cache.lastFinal = FinalHash(final)
+	h := cache.finalHash(final)
+	n := 200
+	if p.finalLen != 0 {
+		n = p.finalLen
 	}
+	h.Write((*[200]byte)(unsafe.Pointer(&cache.finalState[0]))[:n])
+	// skein.Sum writes a whole 64-byte block over the tail of its argument,
+	// so the digest can't go straight into b.
+	return append(b, h.Sum(cache.digest[:0])[:32]...)
+}
 
-	//////////////////////////////////////////////////
-	// as per CNS008 sec.5 Result Calculation
-	aes.CnExpandKey(cc.finalState[4:8], &cc.rkeys)
-	tmp := cc.finalState[8:24] // a temp pointer
+// resultState encrypts the scratchpad into the keccak state, the part of
+// result calculation before the final permutation. The keys are expanded from
+// state[4:8], and the result overwrites state[8:24]; no other word of the
+// state changes.
+func (cache *Cache) resultState(p *variantParams) {
+	p.expandKey(cache.finalState[4:8], &cache.rkeys)
+	tmp := cache.finalState[8:24] // a temp pointer
 
-	for i := 0; i < 2*1024*1024/8; i += 16 {
+	for i := 0; i < scratchpadSize/8; i += 16 {
 		for j := 0; j < 16; j += 2 {
-			cc.scratchpad[i+j] ^= tmp[j]
-			cc.scratchpad[i+j+1] ^= tmp[j+1]
-			aes.CnRounds(cc.scratchpad[i+j:], cc.scratchpad[i+j:], &cc.rkeys)
+			cache.scratchpad[i+j] ^= tmp[j]
+			cache.scratchpad[i+j+1] ^= tmp[j+1]
+			p.rounds(cache.scratchpad[i+j:], cache.scratchpad[i+j:], &cache.rkeys)
 		}
-		tmp = cc.scratchpad[i : i+16]
+		tmp = cache.scratchpad[i : i+16]
 	}
 
-	copy(cc.finalState[8:24], tmp)
-	sha3.Keccak1600Permute(&cc.finalState)
+	copy(cache.finalState[8:24], tmp)
+}
 
-	// the final hash
-	hp := hashPool[cc.finalState[0]&0x03]
-	h := hp.Get().(hash.Hash)
-	h.Write((*[200]byte)(unsafe.Pointer(&cc.finalState[0]))[:])
-	sum := h.Sum(nil)
-	h.Reset()
-	hp.Put(h)
+// variant1Tweak derives the tweak of variant 1 from the keccak state of data.
+//
+// It reads data[offset:offset+8], which is data[35:43] for the standard
+// variant 1, that's why data must have at least 43 bytes.
+func variant1Tweak(state *[25]uint64, data []byte, offset int) uint64 {
+	// data[offset:offset+8] alone is only bounded by cap(data), make sure it
+	// doesn't read past len(data)
+	_ = data[offset+7]
+	return state[24] ^ binary.LittleEndian.Uint64(data[offset:offset+8])
+}
 
-	return sum
+// variant1Mask returns the bits VARIANT1_1 of monero flips in byte 11 of the
+// block just written, given t, the second word of the block shifted right by
+// 24 bits, i.e. byte 11 at its lowest. Only bit 0, 4 and 5 of byte 11 are
+// read, and only bit 4 and 5 are flipped, by the union of:
+//
+//   - ((^t) & 1) << 4 flips bit 4 if bit 0 is clear
+//   - ((((^t) & 1) << 4) & t) << 1 flips bit 5 if bit 0 is clear and bit 4 is set
+//   - (t & 32) >> 1 flips bit 4 if bit 5 is set
+//
+// This is equivalent to the lookup into 0x75310 by bit 0, 4 and 5 in monero.
+func variant1Mask(t uint64) uint64 {
+	return (((^t) & 1) << 4) | (((((^t) & 1) << 4) & t) << 1) | ((t & 32) >> 1)
+}
+
+// finalHash returns the reset instance of the i-th final hash owned by cache,
+// creating it first if needed.
+func (cache *Cache) finalHash(i int) hash.Hash {
+	h := cache.finalHashes[i]
+	if h == nil {
+		h = newFinalHash[i]()
+		cache.finalHashes[i] = h
+	} else {
+		h.Reset()
+	}
+
+	return h
 }
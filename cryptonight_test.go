@@ -1,13 +1,26 @@
 package cryptonight
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"unsafe"
 
 	"github.com/aead/skein"
 	"github.com/dchest/blake256"
 
 	"ekyu.moe/cryptonight/groestl"
+	"ekyu.moe/cryptonight/internal/aes"
+	"ekyu.moe/cryptonight/internal/sha3"
 	"ekyu.moe/cryptonight/jh"
 )
 
@@ -16,50 +29,15 @@ type hashSpec struct {
 	variant       int
 }
 
+// the known-answer vectors of each variant, from testdata/cn_<variant>.txt
 var (
-	hashSpecsV0 = []hashSpec{
-		// From CNS008
-		{"", "eb14e8a833fac6fe9a43b57b336789c46ffe93f2868452240720607b14387e11", 0},
-		{
-			"5468697320697320612074657374", // "This is a test"
-			"a084f01d1437a09c6985401b60d43554ae105802c5f5d8a9b3253649c0be6605",
-			0,
-		},
+	hashSpecsV0 = loadHashSpecs(0)
+	hashSpecsV1 = loadHashSpecs(1)
+	hashSpecsV2 = loadHashSpecs(2)
 
-		// From monero: tests/hash/tests-slow.txt
-		{"6465206f6d6e69627573206475626974616e64756d", "2f8e3df40bd11f9ac90c743ca8e32bb391da4fb98612aa3b6cdc639ee00b31f5", 0},
-		{"6162756e64616e732063617574656c61206e6f6e206e6f636574", "722fa8ccd594d40e4a41f3822734304c8d5eff7e1b528408e2229da38ba553c4", 0},
-		{"63617665617420656d70746f72", "bbec2cacf69866a8e740380fe7b818fc78f8571221742d729d9d02d7f8989b87", 0},
-		{"6578206e6968696c6f206e6968696c20666974", "b1257de4efc5ce28c6b40ceb1c6c8f812a64634eb3e81c5220bee9b2b76a6f05", 0},
-	}
-	hashSpecsV1 = []hashSpec{
-		// From monero: tests/hash/tests-slow-1.txt
-		{"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000", "b5a7f63abb94d07d1a6445c36c07c7e8327fe61b1647e391b4c7edae5de57a3d", 1},
-		{"00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000", "80563c40ed46575a9e44820d93ee095e2851aa22483fd67837118c6cd951ba61", 1},
-		{"8519e039172b0d70e5ca7b3383d6b3167315a422747b73f019cf9528f0fde341fd0f2a63030ba6450525cf6de31837669af6f1df8131faf50aaab8d3a7405589", "5bb40c5880cef2f739bdb6aaaf16161eaae55530e7b10d7ea996b751a299e949", 1},
-		{"37a636d7dafdf259b7287eddca2f58099e98619d2f99bdb8969d7b14498102cc065201c8be90bd777323f449848b215d2977c92c4c1c2da36ab46b2e389689ed97c18fec08cd3b03235c5e4c62a37ad88c7b67932495a71090e85dd4020a9300", "613e638505ba1fd05f428d5c9f8e08f8165614342dac419adc6a47dce257eb3e", 1},
-		{"38274c97c45a172cfc97679870422e3a1ab0784960c60514d816271415c306ee3a3ed1a77e31f6a885c3cb", "ed082e49dbd5bbe34a3726a0d1dad981146062b39d36d62c71eb1ed8ab49459b", 1},
-
-		// Produced by monero: src/crypto/slow-hash.c:cn_slow_hash
-		{
-			"e5ad98e59ca8e8a8bce6988ee38292e38081e38193e381aee682b2e9b3b4e38292e38081e68896e38184e381afe6ad8ce38292",
-			"24aa73ab3b1e74bf119b31c62470e5cf29dde98c9a8af33ac243d3103ebca0e5",
-			1,
-		},
-	}
-	hashSpecsV2 = []hashSpec{
-		// From monero: tests/hash/test-slow-2.txt
-		{"5468697320697320612074657374205468697320697320612074657374205468697320697320612074657374", "4cf1ff9ca46eb433b36cd9f70e02b14cc06bfd18ca77fa9ccaafd1fd96c674b0", 2},
-		{"4c6f72656d20697073756d20646f6c6f722073697420616d65742c20636f6e73656374657475722061646970697363696e67", "7d292e43f4751714ec07dbcb0e4bbffe2a7afb6066420960684ff57d7474c871", 2},
-		{"656c69742c2073656420646f20656975736d6f642074656d706f7220696e6369646964756e74207574206c61626f7265", "335563425256edebf1d92dc342369c2f4770ebb4112ba975659bd8a0f210abd0", 2},
-		{"657420646f6c6f7265206d61676e6120616c697175612e20557420656e696d206164206d696e696d2076656e69616d2c", "47758e86d2f57210366cec36fff26f9464d89efd116fe6ef28b718b5da120801", 2},
-		{"71756973206e6f737472756420657865726369746174696f6e20756c6c616d636f206c61626f726973206e697369", "48787b48d5c68f0c1dd825c32580af741cc0ee314f08133135c1e86d87a24a95", 2},
-		{"757420616c697175697020657820656120636f6d6d6f646f20636f6e7365717561742e20447569732061757465", "93bdf47495854f7cfaaca1af8c0f39ef4a3024c10eb0dea23726b0e06ef29e84", 2},
-		{"697275726520646f6c6f7220696e20726570726568656e646572697420696e20766f6c7570746174652076656c6974", "a375a71d0541057ccc96719150dfe10b6e6f486b19cf4a0835e19605413a8417", 2},
-		{"657373652063696c6c756d20646f6c6f726520657520667567696174206e756c6c612070617269617475722e", "163478a76f8f1432533fbdd1284d65c89f37479e54f20841c6ce4eba56c73854", 2},
-		{"4578636570746575722073696e74206f6363616563617420637570696461746174206e6f6e2070726f6964656e742c", "356b0470c6eea75cad7a108179e232905b23bdaf03c2824c6e619d503ee93677", 2},
-		{"73756e7420696e2063756c706120717569206f666669636961206465736572756e74206d6f6c6c697420616e696d20696420657374206c61626f72756d2e", "a47e2b007dc25bb279e197a1b91f67ecebe2ddd8791cd32dd2cb76dd21ed943f", 2},
-	}
+	// one vector of each variant, for the paths that only need to agree
+	// with Sum on every variant
+	hashSpecsSample = []hashSpec{hashSpecsV0[1], hashSpecsV1[0], hashSpecsV2[0]}
 )
 
 func run(t *testing.T, hashSpecs []hashSpec) {
@@ -73,10 +51,172 @@ func run(t *testing.T, hashSpecs []hashSpec) {
 }
 
 func TestSum(t *testing.T) {
-	t.Run("v0", func(t *testing.T) { run(t, hashSpecsV0) })
-	t.Run("v1", func(t *testing.T) {
-		run(t, hashSpecsV1)
+	// every file of vectors in testdata, named after its variant
+	files, _ := filepath.Glob("testdata/cn_*.txt")
+	if len(files) < len(variants) {
+		t.Fatalf("expected vectors of all %d variants, got %d files\n", len(variants), len(files))
+	}
+	for _, file := range files {
+		variant, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "cn_"), ".txt"))
+		if err != nil {
+			t.Fatalf("%s: not named after a variant\n", file)
+		}
+		specs := loadHashSpecs(variant)
+		t.Run(VariantString(variant), func(t *testing.T) { run(t, specs) })
+	}
+
+	t.Run("cn/1 short input", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected to panic, got nothing.")
+			}
+		}()
+
+		Sum([]byte("Obviously less than 43 bytes"), 1)
+	})
+}
+
+func TestVariant1Boundary(t *testing.T) {
+	// both are official vectors, and are exactly 43 bytes long
+	specs := []struct {
+		hashSpec
+		tweak uint64
+	}{
+		{hashSpecsV1[0], 0xb353d1e7a3b8d9ec},
+		{hashSpecsV1[4], 0x998d597a716ca38c},
+	}
+
+	for i, v := range specs {
+		in, _ := hex.DecodeString(v.input)
+		if len(in) != 43 {
+			t.Fatalf("\n[%d] expected input of 43 bytes, got %d", i, len(in))
+		}
+
+		var st [25]uint64
+		sha3.Keccak1600State(&st, in)
+		if tweak := variant1Tweak(&st, in, 35); tweak != v.tweak {
+			t.Errorf("\n[%d] expected tweak:\n\t%#016x\ngot:\n\t%#016x\n", i, v.tweak, tweak)
+		}
+
+		if result := Sum(in, 1); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Fatalf("\n[%d] expected to panic on 42 bytes, got nothing.", i)
+				}
+			}()
+
+			Sum(in[:42], 1)
+		}()
+	}
+}
+
+func TestVariant1TweakByteOrder(t *testing.T) {
+	// monero reads the tweak as the uint64 at byte 192 of the keccak state,
+	// xored with the uint64 at byte 35 of the input, both in little endian.
+	// The state words of this package are little endian by definition, as
+	// the sponge loads and stores them, whatever the byte order of the host,
+	// so state[24] must hold bytes 192 to 199 with byte 192 lowest.
+	var stBytes [200]byte
+	copy(stBytes[192:], []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88})
+	var st [25]uint64
+	for i := range st {
+		st[i] = binary.LittleEndian.Uint64(stBytes[8*i:])
+	}
+
+	// one byte past the tweak window, so its upper neighbor can be changed
+	data := make([]byte, 44)
+	copy(data[35:], []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08})
+
+	// 0x8877665544332211 ^ 0x0807060504030201
+	const expected = 0x8070605040302010
+	if tweak := variant1Tweak(&st, data, 35); tweak != expected {
+		t.Errorf("expected tweak:\n\t%#016x\ngot:\n\t%#016x\n", uint64(expected), tweak)
+	}
+
+	// the tweak only depends on those 16 bytes, not on their neighbors
+	st[23], data[34], data[43] = ^st[23], ^data[34], ^data[43]
+	if tweak := variant1Tweak(&st, data, 35); tweak != expected {
+		t.Errorf("expected tweak:\n\t%#016x\ngot:\n\t%#016x\n", uint64(expected), tweak)
+	}
+}
+
+func TestSumLight(t *testing.T) {
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		if result := SumLight(in, v.variant); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+}
+
+func TestBackend(t *testing.T) {
+	if b := Backend(); b != "aes-ni" && b != "go" {
+		t.Errorf("unexpected backend %q\n", b)
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	info := BuildInfo()
+	for _, fact := range []string{"aes=" + Backend(), "sqrt=" + sqrtImpl, "cn/0,cn/1,cn/2", runtime.GOARCH} {
+		if !strings.Contains(info, fact) {
+			t.Errorf("expected %q in %q\n", fact, info)
+		}
+	}
+}
+
+func TestSumAll(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV1[0].input)
+	sums := SumAll(in)
+	if len(sums) != 3 {
+		t.Fatalf("expected 3 variants, got %d\n", len(sums))
+	}
+	for variant, sum := range sums {
+		if expected := Sum(in, variant); !bytes.Equal(sum, expected) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", variant, expected, sum)
+		}
+	}
+
+	// variant 1 is skipped on short input
+	sums = SumAll(in[:42])
+	if _, ok := sums[1]; ok || len(sums) != 2 {
+		t.Errorf("expected variant 0 and 2 only, got %d variants\n", len(sums))
+	}
+	for variant, sum := range sums {
+		if expected := Sum(in[:42], variant); !bytes.Equal(sum, expected) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", variant, expected, sum)
+		}
+	}
+}
 
+func TestSumShortInput(t *testing.T) {
+	// Expected values for variant 2 and the one-byte inputs are produced by
+	// this package, whose output is verified against the vectors above.
+	specs := []struct {
+		input   []byte
+		variant int
+		output  string
+	}{
+		{nil, 0, "eb14e8a833fac6fe9a43b57b336789c46ffe93f2868452240720607b14387e11"},
+		{[]byte{}, 0, "eb14e8a833fac6fe9a43b57b336789c46ffe93f2868452240720607b14387e11"},
+		{[]byte{0x00}, 0, "ed9f9bf165801f2c715fa456727a90cca41237aeda545ee6eec878f4bbf5fcdc"},
+		{[]byte{0xff}, 0, "bd5384c5963f84ff7ecc22dc39bb7b975e47a8f51424d0b6756eb7a0f62269b5"},
+		{nil, 2, "b75bb574d333cc0f1f5f690c064778579b1325d5f45373fb194d24d11cd99e4f"},
+		{[]byte{}, 2, "b75bb574d333cc0f1f5f690c064778579b1325d5f45373fb194d24d11cd99e4f"},
+		{[]byte{0x00}, 2, "27ff57962a6068d39fc39a9fe0d2656985df0778293cb7e7867f32b0697c0d64"},
+		{[]byte{0xff}, 2, "bbdda5499fd7f6cec3002707babe1ffd9fab8b7ff47230c40154e3be315a23c6"},
+	}
+	for i, v := range specs {
+		result := Sum(v.input, v.variant)
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+
+	for _, in := range [][]byte{nil, {}, {0x00}} {
 		func() {
 			defer func() {
 				if r := recover(); r == nil {
@@ -84,10 +224,288 @@ func TestSum(t *testing.T) {
 				}
 			}()
 
-			Sum([]byte("Obviously less than 43 bytes"), 1)
+			Sum(in, 1)
 		}()
-	})
-	t.Run("v2", func(t *testing.T) { run(t, hashSpecsV2) })
+	}
+}
+
+func TestSumUnsupportedVariant(t *testing.T) {
+	for _, variant := range []int{-1, 3, 5} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrUnsupportedVariant {
+					t.Errorf("[%d] expected to panic with ErrUnsupportedVariant, got %v\n", variant, r)
+				}
+			}()
+
+			Sum(nil, variant)
+		}()
+
+		if _, err := TrySum(nil, variant); err != ErrUnsupportedVariant {
+			t.Errorf("[%d] expected ErrUnsupportedVariant, got %v\n", variant, err)
+		}
+	}
+}
+
+func TestSumWithBuffer(t *testing.T) {
+	cache := new(Cache)
+	dst := make([]byte, 0, 32)
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		result := cache.SumWithBuffer(dst, in, v.variant)
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+		if &result[0] != &dst[:1][0] {
+			t.Errorf("[%d] expected the digest in dst\n", i)
+		}
+
+		if n := testing.AllocsPerRun(2, func() { cache.SumWithBuffer(dst, in, v.variant) }); n != 0 {
+			t.Errorf("[%d] expected no allocation, got %v\n", i, n)
+		}
+	}
+
+	// a short dst is replaced
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	if result := cache.SumWithBuffer(make([]byte, 8), in, 0); hex.EncodeToString(result) != hashSpecsV0[1].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%x\n", hashSpecsV0[1].output, result)
+	}
+}
+
+func TestAddrMask(t *testing.T) {
+	if int(addrMask) != len(Cache{}.scratchpad)*8-16 {
+		t.Fatalf("addrMask %#x doesn't match a scratchpad of %d bytes\n", addrMask, len(Cache{}.scratchpad)*8)
+	}
+
+	// TO_ADDR must give a 16-byte block, and the 64-byte block around it for
+	// the variant 2 shuffle, inside the scratchpad, and reach its last block.
+	// The sizes besides 2 MiB are those of the other CryptoNight variants
+	// out there, checking the formula of addrMask is not tied to 2 MiB. The
+	// masks are the ones their reference implementations hard-code, such as
+	// 0x3ffff0 of cn-heavy.
+	r := rand.New(rand.NewSource(0))
+	for _, v := range []struct{ size, mask uint64 }{
+		{256 << 10, 0x3fff0}, // cn-pico
+		{1 << 20, 0xffff0},   // cn-lite
+		{scratchpadSize, 0x1ffff0},
+		{4 << 20, 0x3ffff0}, // cn-heavy
+	} {
+		size, mask := v.size, v.size-16
+		if mask != v.mask {
+			t.Errorf("[%d] expected mask %#x, got %#x\n", size, v.mask, mask)
+		}
+		words := []uint64{0, mask, ^mask, math.MaxUint64}
+		for i := 0; i < 10000; i++ {
+			words = append(words, r.Uint64())
+		}
+
+		for _, w := range words {
+			addr := (w & mask) >> 3
+			if addr%2 != 0 || addr+2 > size/8 || addr&^7+8 > size/8 {
+				t.Fatalf("[%d] word %#x gives %d, out of bounds\n", size, w, addr)
+			}
+		}
+		if last := (math.MaxUint64 & mask) >> 3; last != size/8-2 {
+			t.Errorf("[%d] expected the last block at %d, got %d\n", size, size/8-2, last)
+		}
+	}
+}
+
+func TestCacheReuse(t *testing.T) {
+	// a reused cache must not leak any state from the previous Sum
+	a, _ := hex.DecodeString(hashSpecsV1[2].input)
+	b, _ := hex.DecodeString(hashSpecsV1[3].input)
+	cache := new(Cache)
+	for _, variant := range SupportedVariants() {
+		first := cache.Sum(a, variant)
+		cache.Sum(b, variant)
+		if again := cache.Sum(a, variant); !bytes.Equal(first, again) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", variant, first, again)
+		}
+		if fresh := new(Cache).Sum(a, variant); !bytes.Equal(first, fresh) {
+			t.Errorf("\n[%d] expected the same as a fresh cache:\n\t%x\ngot:\n\t%x\n", variant, fresh, first)
+		}
+	}
+}
+
+func TestCacheAcrossVariants(t *testing.T) {
+	// alternating variants on one cache, each on a vector of its own
+	specs := []hashSpec{
+		hashSpecsV2[0], hashSpecsV1[2], hashSpecsV0[1], hashSpecsV2[1],
+		hashSpecsV0[2], hashSpecsV1[3], hashSpecsV2[0], hashSpecsV1[2],
+	}
+	cache := new(Cache)
+	for i, v := range specs {
+		in, _ := hex.DecodeString(v.input)
+		if result := hex.EncodeToString(cache.Sum(in, v.variant)); result != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, v.output, result)
+		}
+	}
+}
+
+func TestCacheConcurrentMisuse(t *testing.T) {
+	cache := new(Cache)
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected to panic, got nothing.")
+			}
+		}()
+
+		// pretend another Sum is running
+		cache.running = 1
+		cache.Sum(nil, 0)
+	}()
+
+	// a cache must be reusable after a Sum it hosted panicked
+	cache.running = 0
+	func() {
+		defer func() { recover() }()
+		cache.Sum(nil, 1)
+	}()
+	if result := hex.EncodeToString(cache.Sum(nil, 0)); result != hashSpecsV0[0].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", hashSpecsV0[0].output, result)
+	}
+}
+
+// TestUnsafeAliasing hashes on several caches at once, with GC cycles in
+// between, to exercise the unsafe conversions into Cache. It is meant to be run
+// with -race.
+func TestUnsafeAliasing(t *testing.T) {
+	specs := make([]hashSpec, 0, len(hashSpecsV0)+len(hashSpecsV1)+len(hashSpecsV2))
+	specs = append(specs, hashSpecsV0...)
+	specs = append(specs, hashSpecsV1...)
+	specs = append(specs, hashSpecsV2...)
+
+	rounds := 3
+	if testing.Short() {
+		rounds = 1
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			cache := new(Cache)
+			for r := 0; r < rounds; r++ {
+				for i := range specs {
+					v := specs[(i+w)%len(specs)]
+					in, _ := hex.DecodeString(v.input)
+					if result := hex.EncodeToString(cache.Sum(in, v.variant)); result != v.output {
+						t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", v.output, result)
+					}
+					runtime.GC()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestUnsafePaths(t *testing.T) {
+	// TestUnsafeAliasing covers Sum on caches of the heap; these are the
+	// other paths with unsafe conversions, run concurrently under the race
+	// detector as well
+	v2, _ := hex.DecodeString(hashSpecsV2[0].input)
+	sbox := StandardSBox()
+	custom := CustomParamsOf(2)
+	custom.SBox = &sbox
+
+	paths := map[string]func(cache *Cache) []byte{
+		"sbox": func(cache *Cache) []byte {
+			sum, _ := cache.SumCustom(v2, custom)
+			return sum
+		},
+		"final": func(cache *Cache) []byte {
+			return cache.SumFixedFinalizer(v2, 2, FinalHash(v2Final(v2)))
+		},
+		"state": func(cache *Cache) []byte {
+			st := Keccak1600Absorb(v2)
+			return cache.SumFromState(&st, 2)
+		},
+	}
+	for name, alloc := range map[string]ScratchpadAllocator{
+		"heap allocator":      HeapAllocator{},
+		"huge page allocator": HugePageAllocator{},
+	} {
+		alloc := alloc
+		paths[name] = func(*Cache) []byte {
+			cache, err := NewCacheWithAllocator(alloc)
+			if err != nil {
+				return Sum(v2, 2) // unsupported, nothing to check
+			}
+			defer cache.Free()
+			return cache.Sum(v2, 2)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for name, path := range paths {
+		wg.Add(1)
+		go func(name string, path func(*Cache) []byte) {
+			defer wg.Done()
+
+			cache := new(Cache)
+			for r := 0; r < 2; r++ {
+				if result := hex.EncodeToString(path(cache)); result != hashSpecsV2[0].output {
+					t.Errorf("\n%s expected:\n\t%s\ngot:\n\t%s\n", name, hashSpecsV2[0].output, result)
+				}
+				runtime.GC()
+			}
+		}(name, path)
+	}
+	wg.Wait()
+}
+
+// v2Final returns the final hash Sum selects for in under variant 2.
+func v2Final(in []byte) int {
+	cache := new(Cache)
+	cache.Sum(in, 2)
+
+	return int(cache.LastFinalizer())
+}
+
+func TestForceFinalHash(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	cache := new(Cache)
+
+	for i, newHash := range newFinalHash {
+		i := i
+		p := variants[2]
+		p.selectFinal = func(*[25]uint64) int { return i }
+		result := cache.sum(in, &p)
+
+		h := newHash()
+		h.Write((*[200]byte)(unsafe.Pointer(&cache.finalState[0]))[:])
+		if expected := h.Sum(nil); hex.EncodeToString(result) != hex.EncodeToString(expected) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, expected, result)
+		}
+	}
+}
+
+func TestFinalHashReset(t *testing.T) {
+	in := make([]byte, 200)
+	for i := range in {
+		in[i] = byte(i)
+	}
+
+	for i, newHash := range newFinalHash {
+		h := newHash()
+		h.Write(in)
+		expected := h.Sum(nil)
+
+		h = newHash()
+		h.Write([]byte("garbage left by a previous hash"))
+		h.Sum(nil)
+		h.Reset()
+		h.Write(in)
+		if result := h.Sum(nil); hex.EncodeToString(result) != hex.EncodeToString(expected) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, expected, result)
+		}
+	}
 }
 
 func BenchmarkSum(b *testing.B) {
@@ -104,19 +522,19 @@ func BenchmarkSum(b *testing.B) {
 	b.Run("v0-naive", func(b *testing.B) {
 		b.N *= 100
 		for i := 0; i < b.N; i++ {
-			new(cache).sum(data[i&0x03], 0)
+			new(Cache).Sum(data[i&0x03], 0)
 		}
 	})
 	b.Run("v1-naive", func(b *testing.B) {
 		b.N *= 100
 		for i := 0; i < b.N; i++ {
-			new(cache).sum(data[i&0x03], 1)
+			new(Cache).Sum(data[i&0x03], 1)
 		}
 	})
 	b.Run("v2-naive", func(b *testing.B) {
 		b.N *= 100
 		for i := 0; i < b.N; i++ {
-			new(cache).sum(data[i&0x03], 2)
+			new(Cache).Sum(data[i&0x03], 2)
 		}
 	})
 
@@ -144,7 +562,7 @@ func BenchmarkSum(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				new(cache).sum(data[i&0x03], 0)
+				new(Cache).Sum(data[i&0x03], 0)
 				i++
 			}
 		})
@@ -154,7 +572,7 @@ func BenchmarkSum(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				new(cache).sum(data[i&0x03], 1)
+				new(Cache).Sum(data[i&0x03], 1)
 				i++
 			}
 		})
@@ -164,7 +582,7 @@ func BenchmarkSum(b *testing.B) {
 		b.RunParallel(func(pb *testing.PB) {
 			i := 0
 			for pb.Next() {
-				new(cache).sum(data[i&0x03], 2)
+				new(Cache).Sum(data[i&0x03], 2)
 				i++
 			}
 		})
@@ -202,6 +620,54 @@ func BenchmarkSum(b *testing.B) {
 	})
 }
 
+func BenchmarkCore(b *testing.B) {
+	// Non-consensus: every variant is forced to finalize with BLAKE-256, so
+	// the benchmark isolates the AES and memory-hard core from the variable
+	// final hash. This is only reachable from tests.
+	data := []byte("This is a test This is a test This is a test")
+	cache := new(Cache)
+	for i, name := range []string{"v0", "v1", "v2"} {
+		p := variants[i]
+		p.selectFinal = func(*[25]uint64) int { return 0 }
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cache.sum(data, &p)
+			}
+		})
+	}
+}
+
+func BenchmarkSumWithBuffer(b *testing.B) {
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	cache := new(Cache)
+	dst := make([]byte, 32)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		cache.SumWithBuffer(dst, in, 2)
+	}
+}
+
+func BenchmarkScratchpadInit(b *testing.B) {
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	cache := new(Cache)
+	for i := 0; i < b.N; i++ {
+		cache.scratchpadInit(in, &variants[0])
+	}
+}
+
+func BenchmarkExpandKey(b *testing.B) {
+	// one of the two expansions of a hash, compare with BenchmarkSum
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	st := Keccak1600Absorb(in)
+	var rkeys aes.CnKeySchedule
+	for i := 0; i < b.N; i++ {
+		variants[0].expandKey(st[:4], &rkeys)
+	}
+}
+
 func BenchmarkFinalHash(b *testing.B) {
 	// exactly 200 bytes
 	in, _ := hex.DecodeString("54aed57f88c00ccd0ed596ea7a119eab614e4a618d6777e3a7e61b8eb5c10373cf01826848e5036f6a03d4b37f0952679559dd7badfe91aa53edf7a029a4f5ecdd77ca2522357401749d20e53f89251a1e1e617851c1862c1e6008d3874368b07ea6ac411031a2fb95536c6bf5e1d7c991418b5ed4c3174212637249410213fb8cf06be61b77644b9b46d005287b0c6513cf67450b5a924ac69d0cb68680022a394fbc4d5a92d91aba9bc32f54b5a1d176337f167986bc9c04b54ce6a5b81420c0ee28031e731981")
@@ -236,3 +702,195 @@ func BenchmarkFinalHash(b *testing.B) {
 		}
 	})
 }
+
+func TestSumFromState(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range []hashSpec{hashSpecsV0[1], hashSpecsV2[0]} {
+		in, _ := hex.DecodeString(v.input)
+		var state [25]uint64
+		sha3.Keccak1600State(&state, in)
+		saved := state
+
+		if result := cache.SumFromState(&state, v.variant); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+		if state != saved {
+			t.Errorf("[%d] state is modified\n", i)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected to panic, got nothing.")
+		}
+	}()
+
+	cache.SumFromState(new([25]uint64), 1)
+}
+
+func TestSumChained(t *testing.T) {
+	// cn(cn("This is a test")), computed by this package as two calls of Sum
+	// on top of the official vectors; no published vector exists
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	cache := new(Cache)
+	for _, v := range []struct {
+		variant, rounds int
+		output          string
+	}{
+		{0, 1, hashSpecsV0[1].output},
+		{0, 2, "b144ebff029f34e64808c50c84244b053cd017bbcfecda705a8e84e5f521c2be"},
+		{2, 2, "be7af923b3fda558ccef7513df6fba502a1ef370d93d12f3ea0812e20a811052"},
+	} {
+		if result := cache.SumChained(in, v.variant, v.rounds); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d, %d rounds] expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.rounds, v.output, result)
+		}
+	}
+
+	// three rounds are one more Sum
+	expected := Sum(Sum(Sum(in, 2), 2), 2)
+	if result := cache.SumChained(in, 2, 3); !bytes.Equal(result, expected) {
+		t.Errorf("\nexpected:\n\t%x\ngot:\n\t%x\n", expected, result)
+	}
+
+	// variant 1 fails on the digest of the first round, and says so up front
+	in1, _ := hex.DecodeString(hashSpecsV1[0].input)
+	for _, v := range []struct {
+		in              []byte
+		variant, rounds int
+	}{
+		{in, 0, 0},
+		{in1, 1, 2},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("[%d, %d rounds] expected to panic, got nothing.\n", v.variant, v.rounds)
+				}
+			}()
+
+			cache.SumChained(v.in, v.variant, v.rounds)
+		}()
+	}
+}
+
+func TestVariant1Mask(t *testing.T) {
+	// the reference is VARIANT1_1 in monero: src/crypto/slow-hash.c
+	ref := func(tmp byte) byte {
+		const table uint32 = 0x75310
+		index := (((tmp >> 3) & 6) | (tmp & 1)) << 1
+		return tmp ^ byte((table>>index)&0x30)
+	}
+
+	for i := 0; i < 256; i++ {
+		tmp := byte(i)
+		// the higher bits of the word are never read
+		for _, high := range []uint64{0, 0xffffff00, 0x123456700} {
+			if got := tmp ^ byte(variant1Mask(high|uint64(tmp))); got != ref(tmp) {
+				t.Errorf("%#02x: expected %#02x, got %#02x\n", tmp, ref(tmp), got)
+			}
+			if mask := variant1Mask(high | uint64(tmp)); mask&^0x30 != 0 {
+				t.Errorf("%#02x: flips bits out of 4 and 5, %#x\n", tmp, mask)
+			}
+		}
+	}
+
+	// some known values
+	for _, v := range [][2]byte{{0x00, 0x10}, {0x01, 0x01}, {0x10, 0x20}, {0x11, 0x11}, {0x20, 0x30}, {0x21, 0x31}, {0x30, 0x00}, {0x31, 0x21}} {
+		if got := ref(v[0]); got != v[1] {
+			t.Errorf("reference %#02x: expected %#02x, got %#02x\n", v[0], v[1], got)
+		}
+	}
+}
+
+func TestCacheAlignment(t *testing.T) {
+	check := func(name string, cache *Cache) {
+		// the scratchpad is allocated on first use
+		cache.allocScratchpad()
+		if addr := uintptr(unsafe.Pointer(&cache.scratchpad[0])); addr%64 != 0 {
+			t.Errorf("%s: scratchpad at %#x is not 64-byte aligned\n", name, addr)
+		}
+	}
+
+	for i := 0; i < 8; i++ {
+		check("new", new(Cache))
+
+		cache := cachePool.Get().(*Cache)
+		check("cachePool", cache)
+		cachePool.Put(cache)
+
+		p := new(Pool)
+		p.KeepWarm(1)
+		check("Pool", p.Get())
+
+		// the scratchpad is apart from Cache, wherever Cache is
+		embedded := new(struct {
+			pad   [3]byte
+			cache Cache
+		})
+		check("embedded", &embedded.cache)
+	}
+}
+
+func TestSumCacheConsistency(t *testing.T) {
+	// the top-level Sum takes its caches from a pool, and a Cache may be
+	// fresh or reused; all of them must agree, in any order
+	reused := new(Cache)
+	for variant := range variants {
+		for _, n := range []int{0, 1, 43, 76, 137, 1000} {
+			if n < variants[variant].minLen {
+				continue
+			}
+			in := make([]byte, n)
+			for i := range in {
+				in[i] = byte(i*7 + variant)
+			}
+
+			expected := Sum(in, variant)
+			for name, sum := range map[string][]byte{
+				"fresh Cache":  new(Cache).Sum(in, variant),
+				"reused Cache": reused.Sum(in, variant),
+				"Sum again":    Sum(in, variant),
+			} {
+				if !bytes.Equal(sum, expected) {
+					t.Errorf("\n[%d] %d bytes input, %s expected:\n\t%x\ngot:\n\t%x\n", variant, n, name, expected, sum)
+				}
+			}
+		}
+	}
+}
+
+func TestSumLength(t *testing.T) {
+	cache := new(Cache)
+	for variant := range variants {
+		for _, n := range []int{0, 1, 42, 43, 76, 200} {
+			if n < variants[variant].minLen {
+				continue
+			}
+			in := make([]byte, n)
+			for i := range in {
+				in[i] = byte(i)
+			}
+
+			sum := cache.Sum(in, variant)
+			if len(sum) != 32 {
+				t.Errorf("[%d] %d bytes input: expected 32 bytes, got %d\n", variant, n, len(sum))
+			}
+
+			dst := bytes.Repeat([]byte{0xaa}, 40)
+			if err := cache.SumInto(dst, in, variant); err != nil {
+				t.Fatalf("[%d] unexpected error: %v\n", variant, err)
+			}
+			if !bytes.Equal(dst[:32], sum) || !bytes.Equal(dst[32:], bytes.Repeat([]byte{0xaa}, 8)) {
+				t.Errorf("[%d] %d bytes input: expected exactly 32 bytes written, got %x\n", variant, n, dst)
+			}
+		}
+	}
+
+	dst := make([]byte, 31)
+	if err := cache.SumInto(dst, nil, 0); err != io.ErrShortBuffer {
+		t.Errorf("expected io.ErrShortBuffer, got %v\n", err)
+	}
+	if !bytes.Equal(dst, make([]byte, 31)) {
+		t.Errorf("expected nothing written, got %x\n", dst)
+	}
+}
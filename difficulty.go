@@ -2,8 +2,10 @@ package cryptonight
 
 import (
 	"encoding/binary"
+	"io"
 	"math"
 	"math/big"
+	"sync"
 )
 
 var (
@@ -16,9 +18,49 @@ var (
 //
 // Difficulty is slower than CheckHash, so it should only be used when necessary.
 //
+// Difficulty uses the full 256-bit convention, the same as Difficulty256. See
+// DifficultyCompact for the other one.
+//
 // This isn't a part of CryptoNight, but since such demand of checking difficulty
 // is too common, it is thus included in this package.
 func Difficulty(hash []byte) uint64 {
+	return DifficultyBig(hash).Uint64()
+}
+
+// Difficulty256 returns hash's difficulty as 2^256 / hash, taking hash as a
+// 256-bit little-endian integer. This is the convention of monerod, whose
+// check_hash (see CheckHash) accepts a block by the full 256-bit value, and of
+// pools that verify shares the same way, such as monero-stratum. It is the
+// same as Difficulty.
+func Difficulty256(hash []byte) uint64 {
+	return Difficulty(hash)
+}
+
+// DifficultyCompact returns hash's difficulty from its trailing 64 bits only,
+// as (2^64 - 1) / top, where top is hash[24:32] in little endian. This is the
+// convention of the compact stratum targets sent to miners, where xmrig and
+// its derivatives accept a share when top is less than (2^64 - 1) / diff. A
+// zero top gives math.MaxUint64. hash must be at least 32 bytes long,
+// otherwise it will panic straightforward.
+//
+// The two conventions mostly agree, but DifficultyCompact ignores the lower
+// 192 bits of hash and may differ from Difficulty256 by a small amount, so a
+// share right at the threshold can be valid under one and rejected under the
+// other. Use the one the other side of the protocol uses.
+func DifficultyCompact(hash []byte) uint64 {
+	top := binary.LittleEndian.Uint64(hash[24:32])
+	if top == 0 {
+		return math.MaxUint64
+	}
+
+	return math.MaxUint64 / top
+}
+
+// DifficultyBig is like Difficulty, but returns the full 2^256 / hash as a
+// big.Int, taking hash as a 256-bit little-endian integer. It is meant for
+// reporting difficulties that don't fit in an uint64; use CheckHash for
+// checking shares. A zero hash has a difficulty of 0.
+func DifficultyBig(hash []byte) *big.Int {
 	// swap byte order, since SetBytes accepts big instead of little endian
 	buf := make([]byte, 32)
 	for i := 0; i < 16; i++ {
@@ -27,10 +69,111 @@ func Difficulty(hash []byte) uint64 {
 
 	hashBig := new(big.Int).SetBytes(buf)
 	if hashBig.Cmp(bigZero) == 0 {
+		return hashBig
+	}
+
+	return hashBig.Div(oneLsh256, hashBig)
+}
+
+// DifficultyBatch returns the difficulty of each digest in sums, the same as
+// calling Difficulty on each of them, but in one pass that reuses the
+// temporaries of the 256-bit math across digests instead of allocating them
+// per digest. Every digest must be at least 32 bytes long, otherwise it will
+// panic straightforward.
+//
+// It pairs with SumBatch for evaluating a batch of shares.
+func DifficultyBatch(sums [][]byte) []uint64 {
+	diffs := make([]uint64, len(sums))
+	var d difficultyCalc
+	for i, sum := range sums {
+		diffs[i] = d.difficulty(sum)
+	}
+
+	return diffs
+}
+
+// DifficultyBatchInto is like DifficultyBatch, but it reads the digests
+// contiguously from sums, the i-th digest at sums[32*i:32*(i+1)] as written
+// by SumBatchInto, and writes the i-th difficulty to dst[i]. Trailing bytes of
+// sums that don't make up a whole digest are ignored. It returns
+// io.ErrShortBuffer and writes nothing if dst is shorter than len(sums)/32.
+func DifficultyBatchInto(dst []uint64, sums []byte) error {
+	n := len(sums) / 32
+	if len(dst) < n {
+		return io.ErrShortBuffer
+	}
+
+	var d difficultyCalc
+	for i := 0; i < n; i++ {
+		dst[i] = d.difficulty(sums[32*i : 32*(i+1)])
+	}
+
+	return nil
+}
+
+// difficultyCalc holds the temporaries of Difficulty, for reuse across
+// digests.
+type difficultyCalc struct {
+	buf             [32]byte
+	hash, diff, rem big.Int
+}
+
+// difficulty is Difficulty(hash), giving the same result.
+func (d *difficultyCalc) difficulty(hash []byte) uint64 {
+	// swap byte order, since SetBytes accepts big instead of little endian
+	_ = hash[31]
+	for i := 0; i < 32; i++ {
+		d.buf[i] = hash[31-i]
+	}
+
+	d.hash.SetBytes(d.buf[:])
+	if d.hash.Sign() == 0 {
 		return 0
 	}
 
-	return hashBig.Div(oneLsh256, hashBig).Uint64()
+	// QuoRem instead of Div, which would allocate a fresh remainder; the
+	// quotient is the same, as both operands are positive
+	d.diff.QuoRem(oneLsh256, &d.hash, &d.rem)
+	return d.diff.Uint64()
+}
+
+// SumDifficulty calculates a CryptoNight hash digest using cache, and returns
+// the digest together with its difficulty. It is equivalent to calling
+// cache.Sum and then Difficulty on the result.
+//
+// This is the most common operation of a pool, so it is provided for
+// convenience. The difficulty is the one a share achieves, not a check against
+// a target, so it is also what a variable-difficulty pool feeds its controller
+// with; see Target and SumAgainst for the check.
+func (cache *Cache) SumDifficulty(data []byte, variant int) ([]byte, uint64) {
+	sum := cache.Sum(data, variant)
+	return sum, Difficulty(sum)
+}
+
+// SumExpectDifficulty calculates a CryptoNight hash digest using cache, and
+// returns the digest, whether it meets minDiff as checked by CheckHash, and its
+// difficulty as by Difficulty. It is the floor check of a pool, which rejects a
+// share below the difficulty it was assigned in the same call that hashes it.
+//
+// SumExpectDifficulty doesn't detect a share mined under another variant as
+// such. Its digest under variant is as good as random, so it almost always
+// falls short of minDiff and ok is false, but it is then indistinguishable
+// from any other low share. A low minDiff lets a few of those through, the same
+// as low shares of the right variant.
+func (cache *Cache) SumExpectDifficulty(blob []byte, variant int, minDiff uint64) (sum []byte, ok bool, diff uint64) {
+	sum = cache.Sum(blob, variant)
+	return sum, CheckHash(sum, minDiff), Difficulty(sum)
+}
+
+// SumCompact calculates a CryptoNight hash digest using cache, and returns the
+// digest together with its compact comparison value: the last 8 bytes of the
+// digest, sum[24:32], read as a little-endian uint64, so sum[31] is the most
+// significant byte. This is top of DifficultyCompact, which a pool using the
+// compact stratum targets compares directly against the 64-bit target it sent
+// to the miner, with no further decoding of the digest.
+func (cache *Cache) SumCompact(data []byte, variant int) (sum []byte, compact uint64) {
+	sum = cache.Sum(data, variant)
+	return sum, binary.LittleEndian.Uint64(sum[24:32])
 }
 
 // CheckHash checks hash's difficulty against diff. It returns true if hash's
@@ -43,6 +186,8 @@ func Difficulty(hash []byte) uint64 {
 // calculating the exact value of hashDiff.
 //
 // This function is a port of monero: src/cryptonote_basic/difficulty.cpp:check_hash
+// and matches its consensus behavior exactly, including right at the threshold.
+// It uses the full 256-bit convention, as Difficulty256 does.
 //
 // This isn't a part of CryptoNight, but since such demand of checking difficulty
 // is too common, it is thus included in this package.
@@ -71,3 +216,152 @@ func CheckHash(hash []byte, diff uint64) bool {
 
 	return !carry
 }
+
+// CheckHashTarget checks hash against a full 256-bit target. It returns true if
+// hash, interpreted as a 256-bit little endian number, is strictly less than
+// target, interpreted the same way. Hence an all-zero target never passes.
+// Both hash and target must be at least 32 bytes long, otherwise it will panic
+// straightforward.
+//
+// This isn't a part of CryptoNight, but since such demand of checking difficulty
+// is too common, it is thus included in this package.
+func CheckHashTarget(hash, target []byte) bool {
+	_, _ = hash[31], target[31]
+	for i := 31; i >= 0; i-- {
+		if hash[i] != target[i] {
+			return hash[i] < target[i]
+		}
+	}
+
+	return false
+}
+
+// SumCheck calculates a CryptoNight hash digest using cache, and checks it
+// against a full 256-bit little endian target in the same way as
+// CheckHashTarget does. target must be at least 32 bytes long.
+//
+// SumCheck can't reject a candidate any earlier than a full Sum, for any
+// variant. The digest is the output of the final hash over the whole keccak
+// state, which depends on every step before it, and nothing computed earlier
+// bounds the digest. Besides, the result calculation and final hash that
+// follow the memory-hard loop take only about a tenth of a hash, so that is the
+// most even a perfect early reject after the loop would save.
+func (cache *Cache) SumCheck(data []byte, variant int, target []byte) (sum []byte, meets bool) {
+	sum = cache.Sum(data, variant)
+	return sum, CheckHashTarget(sum, target)
+}
+
+// Target is a difficulty target, as a pool assigns to a miner, in the forms
+// both sides of the protocol use: the difficulty itself, the full 256-bit
+// target and the compact 64-bit one. The zero value of Target is difficulty 0,
+// which every hash meets. Target is small, and meant to be passed by value.
+type Target struct {
+	diff uint64
+	full [32]byte
+}
+
+// TargetFromDifficulty returns the Target of difficulty diff.
+func TargetFromDifficulty(diff uint64) Target {
+	t := Target{diff: diff}
+	if diff <= 1 {
+		for i := range t.full {
+			t.full[i] = 0xff
+		}
+		return t
+	}
+
+	// the smallest hash that fails is (2^256 - 1) / diff + 1, see CheckHash
+	max := new(big.Int).Sub(oneLsh256, big.NewInt(1))
+	max.Div(max, new(big.Int).SetUint64(diff))
+	max.Add(max, big.NewInt(1))
+
+	// swap byte order, since Bytes gives big instead of little endian
+	buf := max.Bytes()
+	for i := range buf {
+		t.full[i] = buf[len(buf)-1-i]
+	}
+
+	return t
+}
+
+// Difficulty returns the difficulty of t.
+func (t Target) Difficulty() uint64 {
+	return t.diff
+}
+
+// Bytes returns the full 256-bit target of t in little endian, which a hash
+// meets if it is strictly less than it, as checked by CheckHashTarget. It is
+// the same as Meets, except for difficulty 0 and 1, whose target of 2^256
+// doesn't fit in 32 bytes and is given as 2^256 - 1 instead; only the
+// all-0xff hash tells the two apart.
+func (t Target) Bytes() [32]byte {
+	return t.full
+}
+
+// Compact returns the compact 64-bit target of t, (2^64 - 1) / diff, as sent to
+// miners by stratum. xmrig and its derivatives accept a share when the top of
+// its hash, as read by DifficultyCompact, is less than it. It gives
+// math.MaxUint64 for difficulty 0. See DifficultyCompact on how the compact
+// convention may differ from Meets right at the threshold.
+func (t Target) Compact() uint64 {
+	if t.diff == 0 {
+		return math.MaxUint64
+	}
+
+	return math.MaxUint64 / t.diff
+}
+
+// Meets reports whether sum meets t, as CheckHash does with the difficulty of
+// t. sum must be at least 32 bytes long, otherwise it will panic
+// straightforward.
+func (t Target) Meets(sum []byte) bool {
+	return CheckHash(sum, t.diff)
+}
+
+// SumAgainst calculates a CryptoNight hash digest using cache, and checks it
+// against t with Meets, validating a share in one call.
+func (cache *Cache) SumAgainst(blob []byte, variant int, t Target) (sum []byte, meets bool) {
+	sum = cache.Sum(blob, variant)
+	return sum, t.Meets(sum)
+}
+
+// DiffHistogram counts hash digests by the order of magnitude of their
+// difficulty, for monitoring the shares a pool receives. Bucket k holds the
+// digests of difficulty in [10^k, 10^(k+1)), with a difficulty of 0 counted in
+// bucket 0. Difficulties are computed as by Difficulty.
+//
+// The zero value of DiffHistogram is ready to use, and it is safe for
+// concurrent use.
+type DiffHistogram struct {
+	mu      sync.Mutex
+	buckets [20]uint64 // math.MaxUint64 has 20 decimal digits
+}
+
+// Add counts sum in h. sum must be at least 32 bytes long, otherwise it will
+// panic straightforward.
+func (h *DiffHistogram) Add(sum []byte) {
+	k := 0
+	for diff := Difficulty(sum); diff >= 10; diff /= 10 {
+		k++
+	}
+
+	h.mu.Lock()
+	h.buckets[k]++
+	h.mu.Unlock()
+}
+
+// Snapshot returns the counts of h so far, keyed by bucket. Empty buckets are
+// left out.
+func (h *DiffHistogram) Snapshot() map[int]uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m := make(map[int]uint64)
+	for k, n := range h.buckets {
+		if n != 0 {
+			m[k] = n
+		}
+	}
+
+	return m
+}
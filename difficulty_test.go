@@ -1,7 +1,14 @@
 package cryptonight
 
 import (
+	"bytes"
 	"encoding/hex"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -50,6 +57,55 @@ func TestDifficulty(t *testing.T) {
 	}()
 }
 
+func TestDifficultyCompact(t *testing.T) {
+	// the same hashes as diffSpecs, some of them give a different difficulty
+	// under this convention
+	specs := []diffSpec{
+		{"8e3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000", 1009},
+		{"d3c693d2083888c03bc8dfbca4f32d9692e094722d8cbf4a90aa4c1400000000", 54164528303},
+		{"0000000000000000000000000000000000000000000000000000000000000000", math.MaxUint64},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffff0000000000000000", math.MaxUint64},
+		{"0000000000000000000000000000000000000000000000000000000000000001", 255},
+		{"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff0", 1},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 1},
+	}
+	for i, v := range specs {
+		in, _ := hex.DecodeString(v.input)
+		if diff := DifficultyCompact(in); diff != v.output {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.output, diff)
+		}
+	}
+}
+
+func TestDifficultyBig(t *testing.T) {
+	specs := []struct {
+		input  string // in hex
+		output string // in decimal
+	}{
+		{"0000000000000000000000000000000000000000000000000000000000000000", "0"},
+		// 2^256 / 1
+		{"0100000000000000000000000000000000000000000000000000000000000000", "115792089237316195423570985008687907853269984665640564039457584007913129639936"},
+		// 2^256 / 3
+		{"0300000000000000000000000000000000000000000000000000000000000000", "38597363079105398474523661669562635951089994888546854679819194669304376546645"},
+		// 2^256 / 2^64
+		{"0000000000000000010000000000000000000000000000000000000000000000", "6277101735386680763835789423207666416102355444464034512896"},
+	}
+	for i, v := range specs {
+		in, _ := hex.DecodeString(v.input)
+		if diff := DifficultyBig(in); diff.String() != v.output {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.output, diff)
+		}
+	}
+
+	// it agrees with Difficulty whenever the result fits in uint64
+	for i, v := range diffSpecs {
+		in, _ := hex.DecodeString(v.input)
+		if diff := DifficultyBig(in); !diff.IsUint64() || diff.Uint64() != v.output {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.output, diff)
+		}
+	}
+}
+
 func TestCheckHash(t *testing.T) {
 	for i, v := range diffSpecs[:2] {
 		in, _ := hex.DecodeString(v.input)
@@ -65,6 +121,365 @@ func TestCheckHash(t *testing.T) {
 	}
 }
 
+func TestCheckHashThreshold(t *testing.T) {
+	// CheckHash passes iff hash * diff < 2^256, checked against big.Int right
+	// at the threshold, where a division-based check can be off by one.
+	r := rand.New(rand.NewSource(0))
+	hash := make([]byte, 32)
+	checked := 0
+	for i := 0; i < 10000; i++ {
+		r.Read(hash)
+		// keep the top word small, so that the threshold fits in uint64
+		for j := 24 + r.Intn(8); j < 32; j++ {
+			hash[j] = 0
+		}
+
+		// swap byte order, since SetBytes accepts big instead of little endian
+		buf := make([]byte, 32)
+		for j := range buf {
+			buf[j] = hash[31-j]
+		}
+		hashBig := new(big.Int).SetBytes(buf)
+		if hashBig.Sign() == 0 {
+			continue
+		}
+
+		// the largest diff that passes
+		max := new(big.Int).Sub(oneLsh256, big.NewInt(1))
+		max.Div(max, hashBig)
+		if !max.IsUint64() || max.Uint64() == 0 || max.Uint64() == math.MaxUint64 {
+			continue
+		}
+
+		diff := max.Uint64()
+		if !CheckHash(hash, diff) {
+			t.Fatalf("\n[%d] %x expected to pass %d\n", i, hash, diff)
+		}
+		if CheckHash(hash, diff+1) {
+			t.Fatalf("\n[%d] %x expected not to pass %d\n", i, hash, diff+1)
+		}
+		checked++
+	}
+	if checked < 5000 {
+		t.Fatalf("only %d hashes are checked\n", checked)
+	}
+}
+
+func TestTarget(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	hash := make([]byte, 32)
+	for _, diff := range []uint64{0, 1, 2, 3, 1009, 54164528257, 1 << 63, math.MaxUint64} {
+		target := TargetFromDifficulty(diff)
+		if target.Difficulty() != diff {
+			t.Errorf("[%d] expected the difficulty back, got %d\n", diff, target.Difficulty())
+		}
+
+		expected := uint64(math.MaxUint64)
+		if diff != 0 {
+			expected /= diff
+		}
+		if c := target.Compact(); c != expected {
+			t.Errorf("[%d] expected compact %#016x, got %#016x\n", diff, expected, c)
+		}
+
+		// the full target agrees with Meets on both sides of the threshold
+		full := target.Bytes()
+		if diff > 1 {
+			below := append([]byte(nil), full[:]...)
+			for i := range below { // minus 1, in little endian
+				below[i]--
+				if below[i] != 0xff {
+					break
+				}
+			}
+			if !target.Meets(below) || !CheckHashTarget(below, full[:]) {
+				t.Errorf("[%d] expected %x to meet the target\n", diff, below)
+			}
+			if target.Meets(full[:]) || CheckHashTarget(full[:], full[:]) {
+				t.Errorf("[%d] expected %x not to meet the target\n", diff, full)
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			r.Read(hash)
+			// spread the hashes over many orders of magnitude
+			for j := 31 - r.Intn(32); j < 32; j++ {
+				hash[j] = 0
+			}
+			if target.Meets(hash) != CheckHashTarget(hash, full[:]) {
+				t.Fatalf("[%d] %x: the full target disagrees with Meets\n", diff, hash)
+			}
+		}
+	}
+
+	// the zero value is difficulty 0
+	if !(Target{}).Meets(bytes.Repeat([]byte{0xff}, 32)) {
+		t.Error("expected every hash to meet the zero Target\n")
+	}
+
+	cache := new(Cache)
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	diff := Difficulty(Sum(in, 2))
+	for _, v := range []struct {
+		diff  uint64
+		meets bool
+	}{
+		{diff, true},
+		{diff + 1, false},
+	} {
+		sum, meets := cache.SumAgainst(in, 2, TargetFromDifficulty(v.diff))
+		if hex.EncodeToString(sum) != hashSpecsV2[0].output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", v.diff, hashSpecsV2[0].output, sum)
+		}
+		if meets != v.meets {
+			t.Errorf("[%d] expected meets %v, got %v\n", v.diff, v.meets, meets)
+		}
+	}
+}
+
+func TestSumDifficulty(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsV0 {
+		in, _ := hex.DecodeString(v.input)
+		sum, diff := cache.SumDifficulty(in, v.variant)
+		if hex.EncodeToString(sum) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, sum)
+		}
+		if expected := Difficulty(sum); diff != expected {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, expected, diff)
+		}
+	}
+}
+
+func TestSumExpectDifficulty(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsV1 {
+		in, _ := hex.DecodeString(v.input)
+		expected := Difficulty(Sum(in, v.variant))
+		for _, minDiff := range []uint64{0, expected, expected + 1} {
+			sum, ok, diff := cache.SumExpectDifficulty(in, v.variant, minDiff)
+			if hex.EncodeToString(sum) != v.output {
+				t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, sum)
+			}
+			if diff != expected || ok != (minDiff <= expected) {
+				t.Errorf("[%d] minDiff %d: expected (%v, %d), got (%v, %d)\n", i, minDiff, minDiff <= expected, expected, ok, diff)
+			}
+		}
+	}
+}
+
+func TestSumCompact(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsV2 {
+		in, _ := hex.DecodeString(v.input)
+		sum, compact := cache.SumCompact(in, v.variant)
+		if hex.EncodeToString(sum) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, sum)
+		}
+
+		// sum[31] is the most significant byte
+		var expected uint64
+		for j := 31; j >= 24; j-- {
+			expected = expected<<8 | uint64(sum[j])
+		}
+		if compact != expected {
+			t.Errorf("[%d] expected %#016x, got %#016x\n", i, expected, compact)
+		}
+		if diff := DifficultyCompact(sum); compact != 0 && diff != math.MaxUint64/compact {
+			t.Errorf("[%d] expected the top of DifficultyCompact %v, got %#016x\n", i, diff, compact)
+		}
+	}
+}
+
+func TestCheckHashTarget(t *testing.T) {
+	specs := []struct {
+		hash, target string // both in hex, little endian
+		output       bool
+	}{
+		{
+			"8e3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000",
+			"8e3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000",
+			false,
+		},
+		{
+			"8e3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000",
+			"8f3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000",
+			true,
+		},
+		{
+			"ff3c1865f22801dc3df0a688da80701e2390e7838e65c142604cc00eafe34000",
+			"000000000000000000000000000000000000000000000000000000000000e440",
+			true,
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			false,
+		},
+		{
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff00",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			true,
+		},
+	}
+
+	for i, v := range specs {
+		hash, _ := hex.DecodeString(v.hash)
+		target, _ := hex.DecodeString(v.target)
+		if CheckHashTarget(hash, target) != v.output {
+			t.Errorf("\n[%d] check hash target goes wrong", i)
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatal("expected to panic, got nothing.")
+			}
+		}()
+
+		CheckHashTarget(make([]byte, 32), []byte("Obviously less than 32 bytes"))
+	}()
+}
+
+func TestSumCheck(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	expected, _ := hex.DecodeString(hashSpecsV0[1].output)
+	cache := new(Cache)
+
+	above := append([]byte(nil), expected...)
+	above[31]++
+	for i, v := range []struct {
+		target []byte
+		meets  bool
+	}{
+		{above, true},
+		{expected, false},
+		{make([]byte, 32), false},
+	} {
+		sum, meets := cache.SumCheck(in, 0, v.target)
+		if hex.EncodeToString(sum) != hashSpecsV0[1].output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, hashSpecsV0[1].output, sum)
+		}
+		if meets != v.meets {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.meets, meets)
+		}
+	}
+}
+
+func TestDiffHistogram(t *testing.T) {
+	var h DiffHistogram
+	if snap := h.Snapshot(); len(snap) != 0 {
+		t.Errorf("expected an empty snapshot, got %v\n", snap)
+	}
+
+	// feed every spec of diffSpecs from several goroutines at once
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range diffSpecs {
+				in, _ := hex.DecodeString(v.input)
+				h.Add(in)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 1009, 54164528257, 0, 256, 1 and 1, four times each
+	expected := map[int]uint64{0: 12, 2: 4, 3: 4, 10: 4}
+	if snap := h.Snapshot(); !reflect.DeepEqual(snap, expected) {
+		t.Errorf("expected %v, got %v\n", expected, snap)
+	}
+}
+
+func TestDifficultyBatch(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	var sums [][]byte
+	for _, v := range diffSpecs {
+		in, _ := hex.DecodeString(v.input)
+		sums = append(sums, in)
+	}
+	for i := 0; i < 100; i++ {
+		sum := make([]byte, 32)
+		r.Read(sum)
+		// small hashes too, whose difficulty doesn't fit in 64 bits
+		for j := 32 - i%32; j < 32; j++ {
+			sum[j] = 0
+		}
+		sums = append(sums, sum)
+	}
+
+	var flat []byte
+	for _, sum := range sums {
+		flat = append(flat, sum...)
+	}
+	diffs := DifficultyBatch(sums)
+	into := make([]uint64, len(sums))
+	if err := DifficultyBatchInto(into, append(flat, 0xff)); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	for i, sum := range sums {
+		expected := Difficulty(sum)
+		if diffs[i] != expected {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, expected, diffs[i])
+		}
+		if into[i] != expected {
+			t.Errorf("\n[%d] into, expected:\n\t%v\ngot:\n\t%v\n", i, expected, into[i])
+		}
+	}
+
+	if err := DifficultyBatchInto(into[:len(sums)-1], flat); err != io.ErrShortBuffer {
+		t.Errorf("expected io.ErrShortBuffer, got %v\n", err)
+	}
+}
+
+func BenchmarkDifficultyBatch(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	sums := make([][]byte, 1000)
+	for i := range sums {
+		sums[i] = make([]byte, 32)
+		r.Read(sums[i])
+	}
+
+	b.Run("per-digest", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, sum := range sums {
+				Difficulty(sum)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			DifficultyBatch(sums)
+		}
+	})
+}
+
+func BenchmarkSumCheck(b *testing.B) {
+	// what is left after the memory-hard loop, the most any early reject of a
+	// candidate could skip
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	target := make([]byte, 32)
+	cache := new(Cache)
+	var buf [32]byte
+
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cache.SumCheck(in, 2, target)
+		}
+	})
+	b.Run("after-loop", func(b *testing.B) {
+		cache.Sum(in, 2)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			CheckHashTarget(cache.resultCalc(&variants[2], buf[:0]), target)
+		}
+	})
+}
+
 func BenchmarkDifficulty(b *testing.B) {
 	in, _ := hex.DecodeString("d3c693d2083888c03bc8dfbca4f32d9692e094722d8cbf4a90aa4c1400000000")
 	b.ResetTimer()
@@ -0,0 +1,66 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+// Digest is a CryptoNight hash digest.
+//
+// Digest marshals to and from its hex form, so it serializes cleanly into
+// JSON and other text-based encodings.
+type Digest [32]byte
+
+// SumDigest is like Sum but returns the result as a Digest.
+func (cache *Cache) SumDigest(data []byte, variant int) Digest {
+	var d Digest
+	copy(d[:], cache.Sum(data, variant))
+	return d
+}
+
+// String returns d in hex.
+func (d Digest) String() string {
+	return hex.EncodeToString(d[:])
+}
+
+// MarshalText implements encoding.TextMarshaler. d is encoded in hex.
+func (d Digest) MarshalText() ([]byte, error) {
+	buf := make([]byte, hex.EncodedLen(len(d)))
+	hex.Encode(buf, d[:])
+	return buf, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. text must be exactly 64
+// hex digits.
+func (d *Digest) UnmarshalText(text []byte) error {
+	if len(text) != hex.EncodedLen(len(d)) {
+		return errors.New("cryptonight: digest must be 64 hex digits")
+	}
+
+	var tmp Digest
+	if _, err := hex.Decode(tmp[:], text); err != nil {
+		return err
+	}
+	*d = tmp
+
+	return nil
+}
+
+// Difficulty returns d's difficulty. See the top-level Difficulty for details.
+func (d Digest) Difficulty() uint64 {
+	return Difficulty(d[:])
+}
+
+// DifficultyBig returns d's difficulty as a big.Int. See the top-level
+// DifficultyBig for details.
+func (d Digest) DifficultyBig() *big.Int {
+	return DifficultyBig(d[:])
+}
+
+// MeetsTarget reports whether d's difficulty is equal to or greater than the
+// difficulty target. It checks the same way as CheckHash, without calculating
+// the exact difficulty.
+func (d Digest) MeetsTarget(target uint64) bool {
+	return CheckHash(d[:], target)
+}
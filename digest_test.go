@@ -0,0 +1,67 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsV0 {
+		in, _ := hex.DecodeString(v.input)
+		d := cache.SumDigest(in, v.variant)
+		if d.String() != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, v.output, d)
+		}
+		if expected := Difficulty(d[:]); d.Difficulty() != expected {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, expected, d.Difficulty())
+		}
+
+		text, err := json.Marshal(d)
+		if err != nil {
+			t.Fatalf("\n[%d] marshal: %v", i, err)
+		}
+		if string(text) != `"`+v.output+`"` {
+			t.Errorf("\n[%d] expected:\n\t%q\ngot:\n\t%s\n", i, v.output, text)
+		}
+
+		var decoded Digest
+		if err := json.Unmarshal(text, &decoded); err != nil {
+			t.Fatalf("\n[%d] unmarshal: %v", i, err)
+		}
+		if decoded != d {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, d, decoded)
+		}
+	}
+
+	var d Digest
+	out := hashSpecsV0[0].output
+	for _, text := range []string{"", "00", out[:63], out + "00", "zz" + out[2:]} {
+		if err := d.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("expected error for %q, got nothing.", text)
+		}
+	}
+}
+
+func TestDigestDifficulty(t *testing.T) {
+	for i, v := range diffSpecs {
+		var d Digest
+		if err := d.UnmarshalText([]byte(v.input)); err != nil {
+			t.Fatalf("\n[%d] unmarshal: %v", i, err)
+		}
+
+		if diff := d.Difficulty(); diff != v.output {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, v.output, diff)
+		}
+		if diff, expected := d.DifficultyBig(), DifficultyBig(d[:]); diff.Cmp(expected) != 0 {
+			t.Errorf("\n[%d] expected:\n\t%v\ngot:\n\t%v\n", i, expected, diff)
+		}
+		for _, target := range []uint64{0, 1, v.output, v.output + 1, math.MaxUint64} {
+			if meets, expected := d.MeetsTarget(target), CheckHash(d[:], target); meets != expected {
+				t.Errorf("\n[%d] target %d expected:\n\t%v\ngot:\n\t%v\n", i, target, expected, meets)
+			}
+		}
+	}
+}
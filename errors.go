@@ -0,0 +1,71 @@
+package cryptonight
+
+import "errors"
+
+// ErrHardwareMismatch is returned by SumVerified when hashing the same data
+// twice yields different results, which indicates faulty hardware such as bad
+// RAM or an unstable overclock.
+var ErrHardwareMismatch = errors.New("cryptonight: hardware mismatch, results of repeated hashing differ")
+
+// ErrInvalidWorkers is returned by batch functions when the number of workers
+// is negative.
+var ErrInvalidWorkers = errors.New("cryptonight: number of workers must not be negative")
+
+// ErrUnsupportedVariant is returned when the requested variant, or the
+// algorithm name given to VariantFromString, is not one this package
+// implements.
+var ErrUnsupportedVariant = errors.New("cryptonight: unsupported variant")
+
+// ErrUnknownVariant is the former name of ErrUnsupportedVariant.
+//
+// Deprecated: Use ErrUnsupportedVariant, which is the same error.
+var ErrUnknownVariant = ErrUnsupportedVariant
+
+// ErrBlobTooShort is returned when the input data is shorter than the variant
+// requires, such as less than 43 bytes for variant 1.
+var ErrBlobTooShort = errors.New("cryptonight: blob too short for variant")
+
+// ErrBlobTooLong is returned when the input data is longer than MaxBlobSize.
+var ErrBlobTooLong = errors.New("cryptonight: blob too long")
+
+// ErrInvalidParams is returned when CustomParams describes no valid variant.
+var ErrInvalidParams = errors.New("cryptonight: invalid custom parameters")
+
+// ErrInvalidScratchpad is returned by NewCacheWithAllocator when the allocator
+// returns a scratchpad smaller than ScratchpadSize.
+var ErrInvalidScratchpad = errors.New("cryptonight: allocator returned a scratchpad too small")
+
+// ErrAllocFailed is what an *AllocError reports when the OS refuses memory for
+// a scratchpad. Compare with errors.Is(err, ErrAllocFailed), which matches
+// every *AllocError, on Go 1.13 and later.
+var ErrAllocFailed = errors.New("cryptonight: scratchpad allocation failed")
+
+// AllocError is returned by HugePageAllocator, and so by NewCacheWithAllocator,
+// when the OS refuses memory for a scratchpad, such as under memory pressure.
+// Op is the system call that failed, and Err its error.
+type AllocError struct {
+	Op  string
+	Err error
+}
+
+func (e *AllocError) Error() string {
+	return ErrAllocFailed.Error() + ": " + e.Op + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of the OS.
+func (e *AllocError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrAllocFailed.
+func (e *AllocError) Is(target error) bool {
+	return target == ErrAllocFailed
+}
+
+// ErrHugePagesUnsupported is returned by HugePageAllocator on platforms without
+// huge page support in this package.
+var ErrHugePagesUnsupported = errors.New("cryptonight: huge pages are not supported on this platform")
+
+// ErrInvalidFrame is returned by StripFrame when the length prefix of a framed
+// blob doesn't match the blob.
+var ErrInvalidFrame = errors.New("cryptonight: length prefix doesn't match the framed blob")
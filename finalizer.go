@@ -0,0 +1,83 @@
+package cryptonight
+
+import "strconv"
+
+// FinalHash identifies one of the four final hashes of CryptoNight.
+type FinalHash int
+
+// The final hashes, in the order CNS008 selects them by the lowest two bits of
+// the keccak state.
+const (
+	FinalBLAKE256 FinalHash = iota
+	FinalGroestl256
+	FinalJH256
+	FinalSkein256
+)
+
+// String returns the name of f, as listed by AvailableFinalizers.
+func (f FinalHash) String() string {
+	if f < 0 || int(f) >= len(finalHashNames) {
+		return "unknown"
+	}
+
+	return finalHashNames[f]
+}
+
+// LastFinalizer returns the final hash used by the most recent Sum on cache,
+// as remembered by cache rather than recomputed. It is only meaningful right
+// after a Sum, and before anything else uses cache.
+//
+// This is a cheap diagnostic, such as for logging the distribution of the
+// final hashes across a mining run.
+func (cache *Cache) LastFinalizer() FinalHash {
+	return cache.lastFinal
+}
+
+// SumFixedFinalizer is like Sum, but always finalizes with fin, instead of the
+// final hash CNS008 selects by the keccak state. It panics if fin is not one of
+// the four final hashes, or if variant is not supported.
+//
+// This is non-standard and produces digests no public CryptoNote network
+// accepts, except for data that happens to select fin anyway. It is meant for
+// private test networks and research chains that pin one final hash to
+// simplify verification.
+func (cache *Cache) SumFixedFinalizer(data []byte, variant int, fin FinalHash) []byte {
+	if fin < 0 || int(fin) >= len(fixedFinal) {
+		panic("cryptonight: unknown final hash " + strconv.Itoa(int(fin)))
+	}
+
+	p := *paramsOf(variant)
+	p.selectFinal = fixedFinal[fin]
+
+	return cache.sum(data, &p)
+}
+
+// fixedFinal are the selectors of SumFixedFinalizer, one for each final hash.
+var fixedFinal = [len(newFinalHash)]func(*[25]uint64) int{
+	func(*[25]uint64) int { return 0 },
+	func(*[25]uint64) int { return 1 },
+	func(*[25]uint64) int { return 2 },
+	func(*[25]uint64) int { return 3 },
+}
+
+// finalHashNames are the names of the final hashes, in the order of
+// newFinalHash.
+var finalHashNames = [len(newFinalHash)]string{"blake256", "groestl", "jh", "skein"}
+
+// AvailableFinalizers returns the names of the final hashes linked into the
+// binary, out of blake256, groestl, jh and skein.
+//
+// All four are always linked in this package, and every standard variant
+// requires all four of them, since which one finalizes a hash depends on the
+// hash itself. AvailableFinalizers is only meant for reasoning about the
+// dependency graph, or for research builds that stub out some of them.
+func AvailableFinalizers() []string {
+	names := make([]string, 0, len(newFinalHash))
+	for i, f := range newFinalHash {
+		if f != nil {
+			names = append(names, finalHashNames[i])
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,38 @@
+package cryptonight
+
+// SumForkBoundary calculates the CryptoNight hash digests of data under both
+// oldVariant and newVariant using cache, as a pool validating shares around
+// the height of a network upgrade does, when a share may have been mined for
+// either side of the fork. It returns the same digests as two calls of Sum.
+//
+// Both variants share the keccak state and the scratchpad initialization,
+// which SumForkBoundary computes only once, keeping a copy of the initialized
+// scratchpad for the second variant. This only works because every supported
+// variant uses the same 2 MiB scratchpad, filled the same way. The
+// memory-hard loop, which takes the bulk of a hash, differs between variants
+// and still runs once for each, so SumForkBoundary saves only about the
+// scratchpad initialization, a few percent of a hash, over two calls of Sum,
+// and it allocates the 2 MiB copy on every call. When oldVariant equals
+// newVariant, the digest is calculated once and returned twice.
+//
+// The same requirement on input length of Sum applies to both variants.
+func (cache *Cache) SumForkBoundary(data []byte, oldVariant, newVariant int) (oldSum, newSum []byte) {
+	oldP, newP := paramsOf(oldVariant), paramsOf(newVariant)
+	if oldVariant == newVariant {
+		oldSum = cache.sum(data, oldP)
+		return oldSum, append([]byte(nil), oldSum...)
+	}
+
+	cache.acquire()
+	defer cache.release()
+
+	cache.scratchpadInit(data, oldP)
+	state, pad := cache.finalState, *cache.scratchpad
+
+	oldSum = cache.sumInitialized(nil, data, oldP)
+
+	cache.finalState, *cache.scratchpad = state, pad
+	newSum = cache.sumInitialized(nil, data, newP)
+
+	return oldSum, newSum
+}
@@ -0,0 +1,31 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSumForkBoundary(t *testing.T) {
+	cache := new(Cache)
+	for _, v := range []hashSpec{hashSpecsV1[0], hashSpecsV2[0], hashSpecsV2[1]} {
+		in, _ := hex.DecodeString(v.input)
+		for _, fork := range [][2]int{{0, 1}, {1, 2}, {2, 1}, {2, 2}} {
+			oldSum, newSum := cache.SumForkBoundary(in, fork[0], fork[1])
+			if expected := Sum(in, fork[0]); !bytes.Equal(oldSum, expected) {
+				t.Errorf("\n[%d->%d] old expected:\n\t%x\ngot:\n\t%x\n", fork[0], fork[1], expected, oldSum)
+			}
+			if expected := Sum(in, fork[1]); !bytes.Equal(newSum, expected) {
+				t.Errorf("\n[%d->%d] new expected:\n\t%x\ngot:\n\t%x\n", fork[0], fork[1], expected, newSum)
+			}
+		}
+	}
+
+	// the two digests never share memory
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	oldSum, newSum := cache.SumForkBoundary(in, 2, 2)
+	oldSum[0]++
+	if bytes.Equal(oldSum, newSum) {
+		t.Errorf("expected independent digests, got\n\t%x\nfor both\n", oldSum)
+	}
+}
@@ -0,0 +1,56 @@
+package cryptonight
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadHashSpecs reads the known-answer vectors of variant from
+// testdata/cn_<variant>.txt, in the format of monero: tests/hash: one vector
+// per line, the digest and then the input, both in hex and separated by
+// a space. The input may be empty. Empty lines and lines starting with # are
+// skipped.
+//
+// Adding vectors, such as a coin's whole sequence of blocks, is a matter of
+// dropping them in the file. loadHashSpecs panics on a malformed file, so that
+// no vector is silently skipped.
+func loadHashSpecs(variant int) []hashSpec {
+	name := "testdata/cn_" + strconv.Itoa(variant) + ".txt"
+	f, err := os.Open(name)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	var specs []hashSpec
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 1 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 2 || len(fields[0]) != 64 {
+			panic(name + ":" + strconv.Itoa(line) + ": expected a digest and an input")
+		}
+		for _, field := range fields {
+			if _, err := hex.DecodeString(field); err != nil {
+				panic(name + ":" + strconv.Itoa(line) + ": " + err.Error())
+			}
+		}
+		specs = append(specs, hashSpec{input: fields[1], output: fields[0], variant: variant})
+	}
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+
+	return specs
+}
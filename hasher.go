@@ -0,0 +1,55 @@
+package cryptonight
+
+import "hash"
+
+// hasher is the hash.Hash returned by New. CryptoNight needs the whole input
+// at once, so it buffers everything written and hashes at Sum.
+type hasher struct {
+	variant int
+	p       *variantParams
+	buf     []byte // input written so far, its capacity is kept across Reset
+}
+
+// New returns a new hash.Hash calculating the CryptoNight hash digest of
+// variant. Written data is buffered until Sum, using the internal pool of
+// Cache for the actual calculation.
+//
+// Sum panics with ErrBlobTooShort if the data written is too short for
+// variant, i.e. shorter than 43 bytes for variant 1.
+func New(variant int) hash.Hash {
+	return &hasher{
+		variant: variant,
+		p:       paramsOf(variant),
+	}
+}
+
+// Write appends p to the buffered input. It never returns an error.
+func (h *hasher) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	return len(p), nil
+}
+
+// Sum appends the digest of the data written so far to b. It does not change
+// the underlying state.
+func (h *hasher) Sum(b []byte) []byte {
+	if len(h.buf) < h.p.minLen {
+		panic(ErrBlobTooShort)
+	}
+
+	return append(b, Sum(h.buf, h.variant)...)
+}
+
+// Reset discards the data written so far, keeping the buffer for reuse.
+func (h *hasher) Reset() {
+	h.buf = h.buf[:0]
+}
+
+// Size returns the length of a CryptoNight hash digest, which is 32.
+func (h *hasher) Size() int {
+	return 32
+}
+
+// BlockSize returns the rate of the keccak sponge absorbing the input.
+func (h *hasher) BlockSize() int {
+	return 136
+}
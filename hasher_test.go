@@ -0,0 +1,47 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		h := New(v.variant)
+
+		// one byte at a time
+		for j := range in {
+			h.Write(in[j : j+1])
+		}
+		if result := h.Sum(nil); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+
+		// Sum doesn't change the state, and Reset keeps the buffer
+		buf := h.(*hasher).buf
+		h.Reset()
+		if n := testing.AllocsPerRun(10, func() {
+			h.Reset()
+			h.Write(in)
+		}); n != 0 {
+			t.Errorf("[%d] expected no allocation after Reset, got %v\n", i, n)
+		}
+		if &h.(*hasher).buf[0] != &buf[0] {
+			t.Errorf("[%d] expected the buffer to be reused\n", i)
+		}
+		if result := h.Sum([]byte{0xff}); hex.EncodeToString(result) != "ff"+v.output {
+			t.Errorf("\n[%d] expected:\n\tff%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+
+	h := New(1)
+	h.Write(make([]byte, 42))
+	defer func() {
+		if r := recover(); r != ErrBlobTooShort {
+			t.Fatalf("expected to panic with ErrBlobTooShort, got %v\n", r)
+		}
+	}()
+
+	h.Sum(nil)
+}
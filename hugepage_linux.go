@@ -0,0 +1,74 @@
+package cryptonight
+
+import (
+	"reflect"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// HugePageAllocator is a ScratchpadAllocator backing each scratchpad with huge
+// pages, so that the memory-hard loop, which reads the 2 MiB scratchpad at
+// random, doesn't miss the TLB on every access.
+//
+// On Linux, it maps explicit huge pages with MAP_HUGETLB, which requires them
+// to be reserved, such as by vm.nr_hugepages. Without a reservation, it falls
+// back to an ordinary mapping advised for transparent huge pages. Elsewhere,
+// Alloc returns ErrHugePagesUnsupported. When the OS refuses the mapping
+// altogether, Alloc returns an *AllocError.
+//
+// The memory is not managed by the Go runtime, so a Cache using it must be
+// freed with Cache.Free, or the memory leaks.
+type HugePageAllocator struct {
+	// Lock locks the scratchpads in memory with mlock, so they are never
+	// swapped to disk along with the intermediate state of hashing. Locked
+	// memory counts against RLIMIT_MEMLOCK, which is often small for
+	// unprivileged processes; when it is exceeded, Alloc returns an
+	// *AllocError of mlock rather than a scratchpad that isn't locked.
+	Lock bool
+}
+
+// Alloc maps size bytes of huge pages, and locks them if a.Lock is set.
+func (a HugePageAllocator) Alloc(size int) ([]uint64, error) {
+	const prot = unix.PROT_READ | unix.PROT_WRITE
+	const flags = unix.MAP_PRIVATE | unix.MAP_ANONYMOUS
+
+	b, err := unix.Mmap(-1, 0, size, prot, flags|unix.MAP_HUGETLB)
+	if err != nil {
+		b, err = unix.Mmap(-1, 0, size, prot, flags)
+		if err != nil {
+			return nil, &AllocError{Op: "mmap", Err: err}
+		}
+		// only advice, the mapping works without transparent huge pages
+		unix.Madvise(b, unix.MADV_HUGEPAGE)
+	}
+	if a.Lock {
+		if err := unix.Mlock(b); err != nil {
+			unix.Munmap(b)
+			return nil, &AllocError{Op: "mlock", Err: err}
+		}
+	}
+
+	var s []uint64
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&s))
+	h.Data = uintptr(unsafe.Pointer(&b[0]))
+	h.Len = len(b) / 8
+	h.Cap = h.Len
+
+	return s, nil
+}
+
+// Free unlocks s if a.Lock is set, and unmaps it. s must be returned by Alloc
+// of an allocator with the same Lock.
+func (a HugePageAllocator) Free(s []uint64) {
+	var b []byte
+	h := (*reflect.SliceHeader)(unsafe.Pointer(&b))
+	h.Data = uintptr(unsafe.Pointer(&s[0]))
+	h.Len = len(s) * 8
+	h.Cap = h.Len
+
+	if a.Lock {
+		unix.Munlock(b)
+	}
+	unix.Munmap(b)
+}
@@ -0,0 +1,86 @@
+package cryptonight
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestHugePageAllocatorError(t *testing.T) {
+	// far more than any machine can map, which only 64-bit can ask for
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("a failing size needs a 64-bit int")
+	}
+	_, err := HugePageAllocator{}.Alloc(int(^uint(0) >> 2))
+	ae, ok := err.(*AllocError)
+	if !ok {
+		t.Fatalf("expected an *AllocError, got %v\n", err)
+	}
+	if ae.Op != "mmap" || ae.Err != unix.ENOMEM || !ae.Is(ErrAllocFailed) {
+		t.Errorf("expected ErrAllocFailed of mmap with ENOMEM, got %v\n", err)
+	}
+}
+
+// lockedKB returns VmLck of /proc/self/status, the memory locked by the
+// process in kB.
+func lockedKB(t *testing.T) int {
+	status, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		t.Skipf("no /proc/self/status: %v\n", err)
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, "VmLck:") {
+			kb, _ := strconv.Atoi(strings.Fields(line)[1])
+			return kb
+		}
+	}
+	t.Skip("no VmLck in /proc/self/status")
+	return 0
+}
+
+func TestHugePageAllocatorLock(t *testing.T) {
+	alloc := HugePageAllocator{Lock: true}
+	before := lockedKB(t)
+	s, err := alloc.Alloc(ScratchpadSize)
+	if ae, ok := err.(*AllocError); ok && ae.Op == "mlock" {
+		t.Skipf("not allowed by RLIMIT_MEMLOCK: %v\n", err)
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	if locked := lockedKB(t) - before; locked < ScratchpadSize/1024 {
+		t.Errorf("expected %d kB locked, got %d kB\n", ScratchpadSize/1024, locked)
+	}
+	alloc.Free(s)
+	if locked := lockedKB(t) - before; locked != 0 {
+		t.Errorf("expected nothing locked after Free, got %d kB\n", locked)
+	}
+}
+
+func TestHugePageAllocatorLockLimit(t *testing.T) {
+	// a limit too small for a scratchpad fails the allocation, rather than
+	// leaving the scratchpad unlocked
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	defer unix.Setrlimit(unix.RLIMIT_MEMLOCK, &limit)
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{Cur: 0, Max: limit.Max}); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	alloc := HugePageAllocator{Lock: true}
+	s, err := alloc.Alloc(ScratchpadSize)
+	if err == nil {
+		alloc.Free(s)
+		t.Skip("the process may lock memory beyond RLIMIT_MEMLOCK, such as with CAP_IPC_LOCK")
+	}
+	if ae, ok := err.(*AllocError); !ok || ae.Op != "mlock" || !ae.Is(ErrAllocFailed) {
+		t.Errorf("expected ErrAllocFailed of mlock, got %v\n", err)
+	}
+}
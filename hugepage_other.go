@@ -0,0 +1,19 @@
+// +build !linux
+
+package cryptonight
+
+// HugePageAllocator is a ScratchpadAllocator backing each scratchpad with huge
+// pages. It is only implemented on Linux; elsewhere, Alloc returns
+// ErrHugePagesUnsupported.
+type HugePageAllocator struct {
+	// Lock locks the scratchpads in memory on Linux.
+	Lock bool
+}
+
+// Alloc returns ErrHugePagesUnsupported.
+func (HugePageAllocator) Alloc(size int) ([]uint64, error) {
+	return nil, ErrHugePagesUnsupported
+}
+
+// Free does nothing.
+func (HugePageAllocator) Free([]uint64) {}
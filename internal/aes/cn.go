@@ -7,6 +7,21 @@
 // project that's not CryptoNight associated.
 package aes // import "ekyu.moe/cryptonight/internal/aes"
 
+// Backend returns the name of the implementation in use, chosen at init by
+// probing the CPU: "aes-ni" for the AES-NI assembly, or "go" for the portable
+// table-based one.
+func Backend() string {
+	return backend
+}
+
+// CnKeySchedule holds the 10 round keys expanded by CnExpandKey, as consumed
+// by CnRounds.
+//
+// The layout of the words is up to the implementation in use, so a schedule
+// should only be filled by CnExpandKey, and only be read by the rounds of the
+// same implementation.
+type CnKeySchedule [40]uint32
+
 // CnExpandKey expands exactly 10 round keys.
 //
 // key must have at least 2 elements.
@@ -16,7 +31,7 @@ package aes // import "ekyu.moe/cryptonight/internal/aes"
 //
 // Note that this is CryptoNight specific.
 // This is non-standard AES!
-func CnExpandKey(key []uint64, rkeys *[40]uint32) {
+func CnExpandKey(key []uint64, rkeys *CnKeySchedule) {
 	cnExpandKey(key, rkeys)
 }
 
@@ -26,7 +41,7 @@ func CnExpandKey(key []uint64, rkeys *[40]uint32) {
 //
 // Note that this is CryptoNight specific.
 // This is non-standard AES!
-func CnRounds(dst, src []uint64, rkeys *[40]uint32) {
+func CnRounds(dst, src []uint64, rkeys *CnKeySchedule) {
 	cnRounds(dst, src, rkeys)
 }
 
@@ -35,6 +50,9 @@ func CnRounds(dst, src []uint64, rkeys *[40]uint32) {
 //
 // dst and src must have at least 2 elements.
 //
+// rkey is the round key itself, as the register a of the memory-hard loop, not
+// a CnKeySchedule.
+//
 // Note that this is CryptoNight specific.
 // CnSingleRound * 10 might not be equivalent to one CnRounds.
 func CnSingleRound(dst, src []uint64, rkey *[2]uint64) {
@@ -6,9 +6,22 @@ import (
 
 var (
 	hasAES = cpu.X86.HasAES
+
+	backend = "go"
 )
 
-func cnExpandKey(key []uint64, rkeys *[40]uint32) {
+func init() {
+	if hasAES {
+		backend = "aes-ni"
+	}
+}
+
+// The implementation is picked by branching on hasAES on every call, rather
+// than through function variables set in init: the compiler can't prove that
+// the arguments of an indirect call don't escape, which would move the
+// registers of the memory-hard loop to the heap.
+
+func cnExpandKey(key []uint64, rkeys *CnKeySchedule) {
 	if !hasAES {
 		cnExpandKeyGo(key, rkeys)
 	} else {
@@ -16,7 +29,7 @@ func cnExpandKey(key []uint64, rkeys *[40]uint32) {
 	}
 }
 
-func cnRounds(dst, src []uint64, rkeys *[40]uint32) {
+func cnRounds(dst, src []uint64, rkeys *CnKeySchedule) {
 	if !hasAES {
 		cnRoundsGo(dst, src, rkeys)
 	} else {
@@ -33,7 +46,7 @@ func cnSingleRound(dst, src []uint64, rkey *[2]uint64) {
 }
 
 //go:noescape
-func cnExpandKeyAsm(src *uint64, rkey *uint32)
+func cnExpandKeyAsm(key *uint64, rkey *uint32)
 
 //go:noescape
 func cnRoundsAsm(dst, src *uint64, rkeys *uint32)
@@ -42,6 +42,7 @@ TEXT ·cnSingleRoundAsm(SB), NOSPLIT, $0
 
 // func cnExpandKeyAsm(key *uint64, rkey *uint32)
 // Note that round keys are stored in uint128 format, not uint32
+// Exactly 10 round keys (160 bytes) are written.
 TEXT ·cnExpandKeyAsm(SB), NOSPLIT, $0
     MOVQ key+0(FP), AX
     MOVQ rkey+8(FP), BX
@@ -69,8 +70,6 @@ TEXT ·cnExpandKeyAsm(SB), NOSPLIT, $0
     CALL _expand_key_256a<>(SB)
     AESKEYGENASSIST $0x08, X0, X1
     CALL _expand_key_256b<>(SB)
-    AESKEYGENASSIST $0x10, X2, X1
-    CALL _expand_key_256a<>(SB)
     RET
 
 TEXT _expand_key_128<>(SB), NOSPLIT, $0
@@ -0,0 +1,73 @@
+package aes
+
+import (
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+func TestCnExpandKeyAsm(t *testing.T) {
+	if !hasAES {
+		t.Skip("AES-NI is not available")
+	}
+
+	r := rand.New(rand.NewSource(0))
+	key := make([]uint64, 4)
+	for i := 0; i < 1000; i++ {
+		for j := range key {
+			key[j] = r.Uint64()
+		}
+
+		var expected, got CnKeySchedule
+		cnExpandKeyGo(key, &expected)
+		cnExpandKeyAsm(&key[0], &got[0])
+
+		// cnExpandKeyGo stores each word in big endian for the table lookups
+		// of cnRoundsGo, while AES-NI expects them in little endian.
+		for j := range expected {
+			expected[j] = bits.ReverseBytes32(expected[j])
+		}
+		if got != expected {
+			t.Fatalf("\n[%d] key %x expected:\n\t%x\ngot:\n\t%x\n", i, key, expected, got)
+		}
+
+		// and they agree when used as input of CnRounds on each side
+		var goKeys CnKeySchedule
+		cnExpandKeyGo(key, &goKeys)
+		src := []uint64{r.Uint64(), r.Uint64()}
+		dstGo, dstAsm := make([]uint64, 2), make([]uint64, 2)
+		cnRoundsGo(dstGo, src, &goKeys)
+		cnRoundsAsm(&dstAsm[0], &src[0], &got[0])
+		if dstGo[0] != dstAsm[0] || dstGo[1] != dstAsm[1] {
+			t.Fatalf("\n[%d] rounds expected:\n\t%x\ngot:\n\t%x\n", i, dstGo, dstAsm)
+		}
+	}
+}
+
+func TestBackend(t *testing.T) {
+	expected := "go"
+	if hasAES {
+		expected = "aes-ni"
+	}
+	if b := Backend(); b != expected {
+		t.Errorf("expected %q, got %q\n", expected, b)
+	}
+}
+
+func TestCnSingleRoundAsm(t *testing.T) {
+	if !hasAES {
+		t.Skip("AES-NI is not available")
+	}
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10000; i++ {
+		src := []uint64{r.Uint64(), r.Uint64()}
+		key := [2]uint64{r.Uint64(), r.Uint64()}
+		expected, got := make([]uint64, 2), make([]uint64, 2)
+		cnSingleRoundGo(expected, src, &key)
+		cnSingleRoundAsm(&got[0], &src[0], &key[0])
+		if got[0] != expected[0] || got[1] != expected[1] {
+			t.Fatalf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, expected, got)
+		}
+	}
+}
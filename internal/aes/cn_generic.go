@@ -2,11 +2,13 @@
 
 package aes
 
-func cnExpandKey(key []uint64, rkeys *[40]uint32) {
+const backend = "go"
+
+func cnExpandKey(key []uint64, rkeys *CnKeySchedule) {
 	cnExpandKeyGo(key, rkeys)
 }
 
-func cnRounds(dst, src []uint64, rkeys *[40]uint32) {
+func cnRounds(dst, src []uint64, rkeys *CnKeySchedule) {
 	cnRoundsGo(dst, src, rkeys)
 }
 
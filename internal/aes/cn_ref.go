@@ -4,7 +4,7 @@ import (
 	"unsafe"
 )
 
-func cnExpandKeyGo(key []uint64, rkeys *[40]uint32) {
+func cnExpandKeyGo(key []uint64, rkeys *CnKeySchedule) {
 	for i := 0; i < 4; i++ {
 		rkeys[2*i] = uint32(key[i]&0xff<<24) | uint32(key[i]&0xff00<<8) | uint32(key[i]&0xff0000>>8) | uint32(key[i]&0xff000000>>24)
 		rkeys[2*i+1] = uint32(key[i]&0xff00000000>>8) | uint32(key[i]&0xff0000000000>>24) | uint32(key[i]&0xff000000000000>>40) | uint32(key[i]&0xff00000000000000>>56)
@@ -21,7 +21,7 @@ func cnExpandKeyGo(key []uint64, rkeys *[40]uint32) {
 	}
 }
 
-func cnRoundsGo(dst, src []uint64, rkeys *[40]uint32) {
+func cnRoundsGo(dst, src []uint64, rkeys *CnKeySchedule) {
 	src8 := (*[16]byte)(unsafe.Pointer(&src[0]))
 	dst8 := (*[16]byte)(unsafe.Pointer(&dst[0]))
 
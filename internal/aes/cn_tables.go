@@ -0,0 +1,116 @@
+package aes
+
+import (
+	"unsafe"
+)
+
+// Tables are the lookup tables of the portable AES, derived from an S-box
+// which may differ from the standard one. They are meant for research into
+// AES-like variants of CryptoNight, and always run in pure Go: AES-NI only
+// implements the standard S-box.
+type Tables struct {
+	sbox [256]byte
+	te   [4][256]uint32 // for CnRounds, as te0 to te3
+	ter  [4][256]uint32 // for CnSingleRound, as ter0 to ter3
+}
+
+// StandardSBox returns the S-box of the standard AES.
+func StandardSBox() [256]byte {
+	return sbox0
+}
+
+// NewTables derives the lookup tables from sbox. The tables of StandardSBox
+// are identical to those of the standard implementation.
+func NewTables(sbox *[256]byte) *Tables {
+	t := &Tables{sbox: *sbox}
+	for i, s := range sbox {
+		s1 := uint32(s)
+		s2 := s1 << 1
+		if s2 >= 0x100 {
+			s2 ^= 0x11b
+		}
+		s3 := s2 ^ s1
+
+		w := s2<<24 | s1<<16 | s1<<8 | s3
+		for j := 0; j < 4; j++ {
+			t.te[j][i] = w
+			t.ter[j][i] = w<<24 | w<<8&0xff0000 | w>>8&0xff00 | w>>24
+			w = w<<24 | w>>8
+		}
+	}
+
+	return t
+}
+
+// CnExpandKey is like the top-level CnExpandKey, but with the S-box of t.
+func (t *Tables) CnExpandKey(key []uint64, rkeys *CnKeySchedule) {
+	for i := 0; i < 4; i++ {
+		rkeys[2*i] = uint32(key[i]&0xff<<24) | uint32(key[i]&0xff00<<8) | uint32(key[i]&0xff0000>>8) | uint32(key[i]&0xff000000>>24)
+		rkeys[2*i+1] = uint32(key[i]&0xff00000000>>8) | uint32(key[i]&0xff0000000000>>24) | uint32(key[i]&0xff000000000000>>40) | uint32(key[i]&0xff00000000000000>>56)
+	}
+
+	for i := 8; i < 40; i++ {
+		w := rkeys[i-1]
+		if i%8 == 0 {
+			w = t.subw(rotw(w)) ^ (uint32(powx[i/8-1]) << 24)
+		} else if i%8 == 4 {
+			w = t.subw(w)
+		}
+		rkeys[i] = rkeys[i-8] ^ w
+	}
+}
+
+// CnRounds is like the top-level CnRounds, but with the tables of t.
+func (t *Tables) CnRounds(dst, src []uint64, rkeys *CnKeySchedule) {
+	src8 := (*[16]byte)(unsafe.Pointer(&src[0]))
+	dst8 := (*[16]byte)(unsafe.Pointer(&dst[0]))
+	te0, te1, te2, te3 := &t.te[0], &t.te[1], &t.te[2], &t.te[3]
+
+	var s0, s1, s2, s3, t0, t1, t2, t3 uint32
+
+	s0 = uint32(src8[0])<<24 | uint32(src8[1])<<16 | uint32(src8[2])<<8 | uint32(src8[3])
+	s1 = uint32(src8[4])<<24 | uint32(src8[5])<<16 | uint32(src8[6])<<8 | uint32(src8[7])
+	s2 = uint32(src8[8])<<24 | uint32(src8[9])<<16 | uint32(src8[10])<<8 | uint32(src8[11])
+	s3 = uint32(src8[12])<<24 | uint32(src8[13])<<16 | uint32(src8[14])<<8 | uint32(src8[15])
+
+	for r := 0; r < 10; r++ {
+		t0 = rkeys[4*r+0] ^ te0[uint8(s0>>24)] ^ te1[uint8(s1>>16)] ^ te2[uint8(s2>>8)] ^ te3[uint8(s3)]
+		t1 = rkeys[4*r+1] ^ te0[uint8(s1>>24)] ^ te1[uint8(s2>>16)] ^ te2[uint8(s3>>8)] ^ te3[uint8(s0)]
+		t2 = rkeys[4*r+2] ^ te0[uint8(s2>>24)] ^ te1[uint8(s3>>16)] ^ te2[uint8(s0>>8)] ^ te3[uint8(s1)]
+		t3 = rkeys[4*r+3] ^ te0[uint8(s3>>24)] ^ te1[uint8(s0>>16)] ^ te2[uint8(s1>>8)] ^ te3[uint8(s2)]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+	}
+
+	dst8[0], dst8[1], dst8[2], dst8[3] = byte(s0>>24), byte(s0>>16), byte(s0>>8), byte(s0)
+	dst8[4], dst8[5], dst8[6], dst8[7] = byte(s1>>24), byte(s1>>16), byte(s1>>8), byte(s1)
+	dst8[8], dst8[9], dst8[10], dst8[11] = byte(s2>>24), byte(s2>>16), byte(s2>>8), byte(s2)
+	dst8[12], dst8[13], dst8[14], dst8[15] = byte(s3>>24), byte(s3>>16), byte(s3>>8), byte(s3)
+}
+
+// CnSingleRound is like the top-level CnSingleRound, but with the tables of t.
+func (t *Tables) CnSingleRound(dst, src []uint64, rkey *[2]uint64) {
+	src8 := (*[16]byte)(unsafe.Pointer(&src[0]))
+	dst8 := (*[16]byte)(unsafe.Pointer(&dst[0]))
+	rkey32 := (*[4]uint32)(unsafe.Pointer(&rkey[0]))
+	ter0, ter1, ter2, ter3 := &t.ter[0], &t.ter[1], &t.ter[2], &t.ter[3]
+
+	var t0, t1, t2, t3 uint32
+
+	t0 = rkey32[0] ^ ter0[src8[0]] ^ ter1[src8[5]] ^ ter2[src8[10]] ^ ter3[src8[15]]
+	t1 = rkey32[1] ^ ter0[src8[4]] ^ ter1[src8[9]] ^ ter2[src8[14]] ^ ter3[src8[3]]
+	t2 = rkey32[2] ^ ter0[src8[8]] ^ ter1[src8[13]] ^ ter2[src8[2]] ^ ter3[src8[7]]
+	t3 = rkey32[3] ^ ter0[src8[12]] ^ ter1[src8[1]] ^ ter2[src8[6]] ^ ter3[src8[11]]
+
+	dst8[0], dst8[1], dst8[2], dst8[3] = byte(t0), byte(t0>>8), byte(t0>>16), byte(t0>>24)
+	dst8[4], dst8[5], dst8[6], dst8[7] = byte(t1), byte(t1>>8), byte(t1>>16), byte(t1>>24)
+	dst8[8], dst8[9], dst8[10], dst8[11] = byte(t2), byte(t2>>8), byte(t2>>16), byte(t2>>24)
+	dst8[12], dst8[13], dst8[14], dst8[15] = byte(t3), byte(t3>>8), byte(t3>>16), byte(t3>>24)
+}
+
+// Apply the S-box of t to each byte in w.
+func (t *Tables) subw(w uint32) uint32 {
+	return uint32(t.sbox[w>>24])<<24 |
+		uint32(t.sbox[w>>16&0xff])<<16 |
+		uint32(t.sbox[w>>8&0xff])<<8 |
+		uint32(t.sbox[w&0xff])
+}
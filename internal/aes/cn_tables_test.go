@@ -0,0 +1,48 @@
+package aes
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNewTables(t *testing.T) {
+	sbox := StandardSBox()
+	tables := NewTables(&sbox)
+	if tables.te != [4][256]uint32{te0, te1, te2, te3} {
+		t.Error("te tables of the standard S-box differ\n")
+	}
+	if tables.ter != [4][256]uint32{ter0, ter1, ter2, ter3} {
+		t.Error("ter tables of the standard S-box differ\n")
+	}
+
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 1000; i++ {
+		var (
+			key, src, dst, expected [4]uint64
+			rkeys, expectedRkeys    CnKeySchedule
+			rkey                    [2]uint64
+		)
+		for j := range key {
+			key[j], src[j] = rnd.Uint64(), rnd.Uint64()
+		}
+		rkey[0], rkey[1] = rnd.Uint64(), rnd.Uint64()
+
+		cnExpandKeyGo(key[:], &expectedRkeys)
+		tables.CnExpandKey(key[:], &rkeys)
+		if rkeys != expectedRkeys {
+			t.Fatalf("[%d] CnExpandKey expected:\n\t%x\ngot:\n\t%x\n", i, expectedRkeys, rkeys)
+		}
+
+		cnRoundsGo(expected[:], src[:], &expectedRkeys)
+		tables.CnRounds(dst[:], src[:], &rkeys)
+		if dst != expected {
+			t.Fatalf("[%d] CnRounds expected:\n\t%x\ngot:\n\t%x\n", i, expected, dst)
+		}
+
+		cnSingleRoundGo(expected[:], src[:], &rkey)
+		tables.CnSingleRound(dst[:], src[:], &rkey)
+		if dst != expected {
+			t.Fatalf("[%d] CnSingleRound expected:\n\t%x\ngot:\n\t%x\n", i, expected, dst)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package aes
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// fromHex decodes s into little endian 64-bit words, as CryptoNight loads its
+// AES blocks and keys.
+func fromHex(s string) []uint64 {
+	b, _ := hex.DecodeString(s)
+	w := make([]uint64, len(b)/8)
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint64(b[8*i:])
+	}
+	return w
+}
+
+func toHex(w []uint64) string {
+	b := make([]byte, 8*len(w))
+	for i, v := range w {
+		binary.LittleEndian.PutUint64(b[8*i:], v)
+	}
+	return hex.EncodeToString(b)
+}
+
+func TestCnSingleRoundKat(t *testing.T) {
+	// FIPS-197 Appendix C.1: round[1].start and round[1].k_sch give
+	// round[2].start
+	src := fromHex("00102030405060708090a0b0c0d0e0f0")
+	key := fromHex("d6aa74fdd2af72fadaa678f1d6ab76fe")
+	const expected = "89d810e8855ace682d1843d8cb128fe4"
+
+	dst := make([]uint64, 2)
+	CnSingleRound(dst, src, &[2]uint64{key[0], key[1]})
+	if got := toHex(dst); got != expected {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", expected, got)
+	}
+	cnSingleRoundGo(dst, src, &[2]uint64{key[0], key[1]})
+	if got := toHex(dst); got != expected {
+		t.Errorf("\ngo expected:\n\t%s\ngot:\n\t%s\n", expected, got)
+	}
+}
+
+func TestCnExpandKeyKat(t *testing.T) {
+	// FIPS-197 Appendix A.3: w[8] to w[15] and w[36] to w[39] of the AES-256
+	// key expansion, in the word layout of the portable implementation
+	key := fromHex("603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4")
+	expected := map[int]uint32{
+		8: 0x9ba35411, 9: 0x8e6925af, 10: 0xa51a8b5f, 11: 0x2067fcde,
+		12: 0xa8b09c1a, 13: 0x93d194cd, 14: 0xbe49846e, 15: 0xb75d5b9a,
+		36: 0xc814e204, 37: 0x76a9fb8a, 38: 0x5025c02d, 39: 0x59c58239,
+	}
+
+	var rkeys CnKeySchedule
+	cnExpandKeyGo(key, &rkeys)
+	for i, w := range expected {
+		if rkeys[i] != w {
+			t.Errorf("w[%d] expected %08x, got %08x\n", i, w, rkeys[i])
+		}
+	}
+}
+
+func TestCnKeySchedule(t *testing.T) {
+	// the first two round keys are the key itself, in the word layout of the
+	// implementation in use
+	r := rand.New(rand.NewSource(0))
+	key := make([]uint64, 4)
+	for i := 0; i < 1000; i++ {
+		for j := range key {
+			key[j] = r.Uint64()
+		}
+
+		var rkeys CnKeySchedule
+		CnExpandKey(key, &rkeys)
+		got := make([]uint64, 4)
+		for j := range got {
+			lo, hi := rkeys[2*j], rkeys[2*j+1]
+			if Backend() == "go" {
+				lo, hi = bits.ReverseBytes32(lo), bits.ReverseBytes32(hi)
+			}
+			got[j] = uint64(hi)<<32 | uint64(lo)
+		}
+		if toHex(got) != toHex(key) {
+			t.Fatalf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, key, got)
+		}
+	}
+}
+
+func TestCnRoundsKat(t *testing.T) {
+	// 10 rounds without the initial AddRoundKey, keyed by the first 10 round
+	// keys of AES-256, on the key and plaintext of FIPS-197 Appendix C.3.
+	// Produced by the byte-oriented model of CNS008 in the tests of package
+	// cryptonight, which derives its S-box from the definition.
+	key := fromHex("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	src := fromHex("00112233445566778899aabbccddeeff")
+	const expected = "de4320be12e89f05ebe3cb2be09aa7ba"
+
+	var rkeys CnKeySchedule
+	dst := make([]uint64, 2)
+	CnExpandKey(key, &rkeys)
+	CnRounds(dst, src, &rkeys)
+	if got := toHex(dst); got != expected {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", expected, got)
+	}
+
+	cnExpandKeyGo(key, &rkeys)
+	cnRoundsGo(dst, src, &rkeys)
+	if got := toHex(dst); got != expected {
+		t.Errorf("\ngo expected:\n\t%s\ngot:\n\t%s\n", expected, got)
+	}
+}
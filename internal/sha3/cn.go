@@ -1,12 +1,46 @@
 package sha3
 
+import "encoding/binary"
+
+// MaxRate is the largest rate of Keccak1600StateRate in bytes, the same as of
+// the sponges of this package.
+const MaxRate = maxRate
+
+// Keccak1600State absorbs data into st with the original Keccak padding and a
+// rate of 136 bytes, overwriting st.
+//
+// It works on st directly instead of through a sponge state, so no part of it
+// escapes to the heap.
 func Keccak1600State(st *[25]uint64, data []byte) {
-	s := &state{rate: 136}
-	s.Write(data)
-	s.padAndPermute(0x01)
-	*st = s.a
+	Keccak1600StateRate(st, data, 136)
+}
+
+// Keccak1600StateRate is Keccak1600State with a rate of rate bytes, which must
+// be a multiple of 8 from 8 to MaxRate.
+func Keccak1600StateRate(st *[25]uint64, data []byte, rate int) {
+	*st = [25]uint64{}
+	for len(data) >= rate {
+		xorInState(st, data[:rate])
+		keccakF1600(st)
+		data = data[rate:]
+	}
+
+	var last [maxRate]byte
+	copy(last[:], data)
+	last[len(data)] = 0x01
+	last[rate-1] ^= 0x80
+	xorInState(st, last[:rate])
+	keccakF1600(st)
 }
 
 func Keccak1600Permute(st *[25]uint64) {
 	keccakF1600(st)
 }
+
+// xorInState xors the whole words of buf into st, in little endian.
+func xorInState(st *[25]uint64, buf []byte) {
+	for i := 0; len(buf) >= 8; i++ {
+		st[i] ^= binary.LittleEndian.Uint64(buf)
+		buf = buf[8:]
+	}
+}
@@ -0,0 +1,51 @@
+package sha3
+
+import "testing"
+
+func TestKeccak1600StateRateBoundary(t *testing.T) {
+	// Keccak1600State pads on its own instead of through the sponge, so the
+	// lengths around multiples of the rate of 136 bytes are checked against
+	// the sponge based legacy Keccak, over the whole state.
+	in := make([]byte, 3*136+1)
+	for i := range in {
+		in[i] = byte(i * 7)
+	}
+
+	for _, n := range []int{0, 1, 134, 135, 136, 137, 271, 272, 273, 407, 408, 409} {
+		d := &state{rate: 136, dsbyte: 0x01}
+		d.Write(in[:n])
+		d.padAndPermute(d.dsbyte)
+
+		var st [25]uint64
+		for i := range st {
+			st[i] = 0xdeadbeef // Keccak1600State overwrites, not absorbs into st
+		}
+		Keccak1600State(&st, in[:n])
+		if st != d.a {
+			t.Errorf("\n[%d bytes] expected:\n\t%x\ngot:\n\t%x\n", n, d.a, st)
+		}
+	}
+}
+
+func TestKeccak1600StateRate(t *testing.T) {
+	// the rates the sponge based legacy Keccak supports, those of Keccak-512,
+	// -384, -256, -224 and SHAKE128, around the multiples of the rate
+	in := make([]byte, 3*MaxRate+1)
+	for i := range in {
+		in[i] = byte(i * 7)
+	}
+
+	for _, rate := range []int{72, 104, 136, 144, MaxRate} {
+		for _, n := range []int{0, 1, rate - 1, rate, rate + 1, 2*rate + 1, 3 * rate} {
+			d := &state{rate: rate, dsbyte: 0x01}
+			d.Write(in[:n])
+			d.padAndPermute(d.dsbyte)
+
+			var st [25]uint64
+			Keccak1600StateRate(&st, in[:n], rate)
+			if st != d.a {
+				t.Errorf("\n[rate %d, %d bytes] expected:\n\t%x\ngot:\n\t%x\n", rate, n, d.a, st)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// +build go1.24
+
+package sha3
+
+import (
+	"bytes"
+	std "crypto/sha3"
+	"math/rand"
+	"testing"
+)
+
+// TestStdDifferential compares this package against crypto/sha3 on random
+// inputs, which exercises the same keccak-f[1600] permutation and sponge that
+// CryptoNight's keccak states are built on.
+func TestStdDifferential(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 2000; i++ {
+		data := make([]byte, r.Intn(1024))
+		r.Read(data)
+
+		// written in random chunks, to go through the buffered paths too
+		d := New256()
+		for p := data; len(p) > 0; {
+			n := r.Intn(len(p) + 1)
+			d.Write(p[:n])
+			p = p[n:]
+		}
+		if expected := std.Sum256(data); !bytes.Equal(d.Sum(nil), expected[:]) {
+			t.Fatalf("[%d] SHA3-256 of %x mismatches", i, data)
+		}
+
+		if got, expected := Sum224(data), std.Sum224(data); got != expected {
+			t.Fatalf("[%d] SHA3-224 of %x mismatches", i, data)
+		}
+		if got, expected := Sum384(data), std.Sum384(data); got != expected {
+			t.Fatalf("[%d] SHA3-384 of %x mismatches", i, data)
+		}
+		if got, expected := Sum512(data), std.Sum512(data); got != expected {
+			t.Fatalf("[%d] SHA3-512 of %x mismatches", i, data)
+		}
+
+		out := make([]byte, r.Intn(512))
+		ShakeSum128(out, data)
+		if expected := std.SumSHAKE128(data, len(out)); !bytes.Equal(out, expected) {
+			t.Fatalf("[%d] SHAKE128 of %x mismatches", i, data)
+		}
+		ShakeSum256(out, data)
+		if expected := std.SumSHAKE256(data, len(out)); !bytes.Equal(out, expected) {
+			t.Fatalf("[%d] SHAKE256 of %x mismatches", i, data)
+		}
+	}
+}
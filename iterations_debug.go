@@ -0,0 +1,18 @@
+// +build cryptonight_debug
+
+package cryptonight
+
+// iterationCount is how many iterations the memory-hard loop ran in the last
+// Sum of a Cache. Only debug builds keep it, see iterations_nodebug.go.
+type iterationCount int
+
+func (n *iterationCount) record(i int) {
+	*n = iterationCount(i)
+}
+
+// lastIterations returns how many iterations the memory-hard loop ran in the
+// last Sum of cache, or 0 if cache hasn't hashed yet. It is for tests that
+// verify the loop count of a variant.
+func (cache *Cache) lastIterations() int {
+	return int(cache.iterations)
+}
@@ -0,0 +1,29 @@
+// +build cryptonight_debug
+
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestLastIterations(t *testing.T) {
+	// the loop count of every variant, including cn/xtl, which has no number
+	cache := new(Cache)
+	if n := cache.lastIterations(); n != 0 {
+		t.Errorf("expected 0 iterations before hashing, got %d\n", n)
+	}
+
+	in, _ := hex.DecodeString(hashSpecsV1[0].input)
+	for _, variant := range SupportedVariants() {
+		cache.Sum(in, variant)
+		if n := cache.lastIterations(); n != 524288 {
+			t.Errorf("%s: expected 524288 iterations, got %d\n", VariantString(variant), n)
+		}
+	}
+
+	cache.SumXTL(in)
+	if n := cache.lastIterations(); n != 524288 {
+		t.Errorf("cn/xtl: expected 524288 iterations, got %d\n", n)
+	}
+}
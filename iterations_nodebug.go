@@ -0,0 +1,9 @@
+// +build !cryptonight_debug
+
+package cryptonight
+
+// iterationCount takes no space in Cache outside of debug builds, see
+// iterations_debug.go.
+type iterationCount struct{}
+
+func (n *iterationCount) record(i int) {}
@@ -0,0 +1,48 @@
+package cryptonight
+
+import (
+	"encoding/binary"
+
+	"ekyu.moe/cryptonight/internal/sha3"
+)
+
+// FastHash calculates cn_fast_hash, which is the original Keccak-256 (not the
+// standardized SHA3-256), as used by CryptoNote for transaction hashes, block
+// IDs and merkle roots.
+//
+// It shares the exact Keccak-1600 implementation CryptoNight uses for its
+// scratchpad initialization.
+//
+// This isn't a part of CryptoNight, but since such demand of CryptoNote hashing
+// is too common, it is thus included in this package.
+func FastHash(data []byte) [32]byte {
+	var (
+		st  [25]uint64
+		sum [32]byte
+	)
+
+	sha3.Keccak1600State(&st, data)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(sum[i*8:], st[i])
+	}
+
+	return sum
+}
+
+// Keccak1600 applies the Keccak-f[1600] permutation to state in place. It is
+// the exact permutation CryptoNight and FastHash are built on, exposed for
+// building other CryptoNote primitives.
+func Keccak1600(state *[25]uint64) {
+	sha3.Keccak1600Permute(state)
+}
+
+// Keccak1600Absorb returns the full Keccak-1600 state after absorbing data
+// with the original Keccak padding and a rate of 136 bytes. Its first 32 bytes
+// in little endian are FastHash of data, and the whole state is what
+// CryptoNight initializes its scratchpad from.
+func Keccak1600Absorb(data []byte) [25]uint64 {
+	var st [25]uint64
+	sha3.Keccak1600State(&st, data)
+
+	return st
+}
@@ -0,0 +1,99 @@
+package cryptonight
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"testing"
+
+	"ekyu.moe/cryptonight/internal/sha3"
+)
+
+func TestFastHash(t *testing.T) {
+	specs := []struct {
+		input, output string
+	}{
+		{"", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{"The quick brown fox jumps over the lazy dog", "4d741b6f1eb29cb2a9b9911c82f56fa8d73b04959d3d9d222895df6c0b28aa15"},
+	}
+
+	for i, v := range specs {
+		sum := FastHash([]byte(v.input))
+		if hex.EncodeToString(sum[:]) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, sum)
+		}
+	}
+
+	// cross-check against the sponge based implementation
+	in := make([]byte, 1000)
+	for i := range in {
+		in[i] = byte(i)
+	}
+	for n := 0; n <= len(in); n += 17 {
+		h := sha3.NewLegacyKeccak256()
+		h.Write(in[:n])
+		expected := h.Sum(nil)
+		if sum := FastHash(in[:n]); hex.EncodeToString(sum[:]) != hex.EncodeToString(expected) {
+			t.Errorf("\n[%d bytes] expected:\n\t%x\ngot:\n\t%x\n", n, expected, sum)
+		}
+	}
+}
+
+func TestKeccak1600(t *testing.T) {
+	// From the Keccak team: KeccakF-1600-IntermediateValues.txt, the
+	// permutation applied to the all-zero state, once and twice.
+	expected := [25]uint64{
+		0xF1258F7940E1DDE7, 0x84D5CCF933C0478A, 0xD598261EA65AA9EE, 0xBD1547306F80494D, 0x8B284E056253D057,
+		0xFF97A42D7F8E6FD4, 0x90FEE5A0A44647C4, 0x8C5BDA0CD6192E76, 0xAD30A6F71B19059C, 0x30935AB7D08FFC64,
+		0xEB5AA93F2317D635, 0xA9A6E6260D712103, 0x81A57C16DBCF555F, 0x43B831CD0347C826, 0x01F22F1A11A5569F,
+		0x05E5635A21D9AE61, 0x64BEFEF28CC970F2, 0x613670957BC46611, 0xB87C5A554FD00ECB, 0x8C3EE88A1CCF32C8,
+		0x940C7922AE3A2614, 0x1841F924A2C509E4, 0x16F53526E70465C2, 0x75F644E97F30A13B, 0xEAF1FF7B5CECA249,
+	}
+
+	var st [25]uint64
+	Keccak1600(&st)
+	if st != expected {
+		t.Fatalf("\nexpected:\n\t%x\ngot:\n\t%x\n", expected, st)
+	}
+
+	Keccak1600(&st)
+	if st[0] != 0x2D5C954DF96ECB3C || st[1] != 0x6A332CD07057B56D || st[24] != 0x20D06CD26A8FBF5C {
+		t.Fatalf("unexpected state after the second permutation:\n\t%x\n", st)
+	}
+}
+
+func TestKeccak1600Absorb(t *testing.T) {
+	for _, in := range []string{"", "The quick brown fox jumps over the lazy dog"} {
+		st := Keccak1600Absorb([]byte(in))
+		sum := FastHash([]byte(in))
+		for i := 0; i < 4; i++ {
+			if got := binary.LittleEndian.Uint64(sum[i*8:]); got != st[i] {
+				t.Errorf("%q: lane %d expected %#x, got %#x\n", in, i, got, st[i])
+			}
+		}
+
+		var expected [25]uint64
+		sha3.Keccak1600State(&expected, []byte(in))
+		if st != expected {
+			t.Errorf("%q: expected:\n\t%x\ngot:\n\t%x\n", in, expected, st)
+		}
+	}
+}
+
+func BenchmarkKeccak1600Absorb(b *testing.B) {
+	// absorbing is negligible next to Sum, aligned or not, so there is no
+	// aligned entry point
+	for _, n := range []int{76, 200} {
+		buf := make([]byte, n+1)
+		b.Run(strconv.Itoa(n)+"-aligned", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Keccak1600Absorb(buf[:n])
+			}
+		})
+		b.Run(strconv.Itoa(n)+"-unaligned", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				Keccak1600Absorb(buf[1:])
+			}
+		})
+	}
+}
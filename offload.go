@@ -0,0 +1,68 @@
+package cryptonight
+
+import "encoding/binary"
+
+// InitScratchpad runs only the scratchpad initialization of Sum on data, as
+// per CNS008 sec.3. Together with Finalize, it exposes the CPU-side stages of
+// Sum separately, for research setups that run the memory-hard loop elsewhere,
+// such as on a coprocessor.
+//
+// The caller is responsible for the loop in between, which works on State and
+// Scratchpad, including the tweak of variant 1. InitScratchpad panics with
+// ErrBlobTooShort on data shorter than variant requires.
+//
+// This is a research API, and it is not needed for normal hashing.
+func (cache *Cache) InitScratchpad(data []byte, variant int) {
+	p := paramsOf(variant)
+	if len(data) < p.minLen {
+		panic(ErrBlobTooShort)
+	}
+
+	cache.acquire()
+	defer cache.release()
+
+	cache.scratchpadInit(data, p)
+}
+
+// Finalize runs only the result calculation of Sum, as per CNS008 sec.5, on
+// the current State and Scratchpad, and returns the hash digest. See
+// InitScratchpad.
+func (cache *Cache) Finalize(variant int) []byte {
+	cache.acquire()
+	defer cache.release()
+
+	cache.allocScratchpad()
+	return cache.resultCalc(paramsOf(variant), nil)
+}
+
+// State returns the keccak state of cache, which is valid between
+// InitScratchpad and Finalize. It aliases the memory of cache.
+func (cache *Cache) State() *[25]uint64 {
+	return &cache.finalState
+}
+
+// Scratchpad returns the 2 MiB scratchpad of cache as little-endian 64-bit
+// words. It aliases the memory of cache, which is allocated first if cache has
+// no scratchpad yet.
+//
+// Writing to the returned slice changes the next Finalize; see ScratchpadBytes
+// for a copy that is safe to keep.
+func (cache *Cache) Scratchpad() []uint64 {
+	cache.allocScratchpad()
+	return cache.scratchpad[:]
+}
+
+// ScratchpadBytes returns a copy of the 2 MiB scratchpad of cache, in the byte
+// order of CNS008 and other implementations, so it can be saved, or compared
+// byte for byte with the scratchpad of another implementation at the same
+// stage, such as right after InitScratchpad. The copy doesn't alias cache, and
+// a fresh one is allocated on every call. cache must not be in use.
+func (cache *Cache) ScratchpadBytes() []byte {
+	cache.allocScratchpad()
+	b := make([]byte, ScratchpadSize)
+	for i, w := range cache.scratchpad {
+		binary.LittleEndian.PutUint64(b[8*i:], w)
+	}
+
+	return b
+}
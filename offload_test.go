@@ -0,0 +1,63 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestInitScratchpadFinalize(t *testing.T) {
+	cache := new(Cache)
+	if n := len(cache.Scratchpad()); n != ScratchpadSize/8 {
+		t.Fatalf("expected a scratchpad of %d words before any Sum, got %d\n", ScratchpadSize/8, n)
+	}
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		cache.InitScratchpad(in, v.variant)
+
+		// the reference loop, i.e. the one Sum uses
+		var v1Tweak uint64
+		if v.variant == 1 {
+			v1Tweak = variant1Tweak(cache.State(), in, 35)
+		}
+		cache.memoryHardLoop(paramsOf(v.variant), v1Tweak)
+
+		if result := cache.Finalize(v.variant); hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != ErrBlobTooShort {
+			t.Fatalf("expected to panic with ErrBlobTooShort, got %v\n", r)
+		}
+	}()
+
+	cache.InitScratchpad([]byte("Obviously less than 43 bytes"), 1)
+}
+
+func TestScratchpadBytes(t *testing.T) {
+	// the checksum of TestScratchpadInitChecksum, over the exported bytes
+	const expected = "5efd6cbe741bfcb5364bd4d8e7bfd0bbd4bad6c1360c1977daa0bc1b7490c4c7"
+
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	cache := new(Cache)
+	cache.InitScratchpad(in, 0)
+	b := cache.ScratchpadBytes()
+	if sum := FastHash(b); hex.EncodeToString(sum[:]) != expected {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%x\n", expected, sum)
+	}
+
+	// byte 8*i of the copy is the lowest byte of word i
+	words := cache.Scratchpad()
+	for _, i := range []int{0, 1, len(words) - 1} {
+		if b[8*i] != byte(words[i]) || b[8*i+7] != byte(words[i]>>56) {
+			t.Errorf("[%d] expected word %#016x in little endian, got %x\n", i, words[i], b[8*i:8*i+8])
+		}
+	}
+
+	// and it is a copy
+	b[0]++
+	if byte(words[0]) == b[0] {
+		t.Error("expected a copy, got the scratchpad itself\n")
+	}
+}
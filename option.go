@@ -0,0 +1,29 @@
+package cryptonight
+
+// CacheOption configures a Cache created by NewCache.
+type CacheOption func(*Cache)
+
+// NewCache returns a new Cache configured by opts. NewCache without options is
+// equivalent to new(Cache).
+func NewCache(opts ...CacheOption) *Cache {
+	cache := new(Cache)
+	for _, opt := range opts {
+		opt(cache)
+	}
+
+	return cache
+}
+
+// WithStrictValidation makes Cache.Sum validate its input with ValidateBlob,
+// and panic with the error, such as ErrBlobTooShort or ErrUnsupportedVariant,
+// on input it would otherwise hash unchecked.
+//
+// Sum can't return an error, so callers that want errors rather than panics
+// should call TrySum, which validates, and returns the error, under either
+// policy. Without the option, Sum is the unchecked fast path; the other
+// methods of Cache never validate beyond what they document.
+func WithStrictValidation() CacheOption {
+	return func(cache *Cache) {
+		cache.strict = true
+	}
+}
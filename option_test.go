@@ -0,0 +1,42 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestWithStrictValidation(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV1[0].input)
+
+	// valid input hashes the same under either policy
+	for _, cache := range []*Cache{NewCache(), NewCache(WithStrictValidation())} {
+		if result := hex.EncodeToString(cache.Sum(in, 1)); result != hashSpecsV1[0].output {
+			t.Errorf("\nstrict=%v expected:\n\t%s\ngot:\n\t%s\n", cache.strict, hashSpecsV1[0].output, result)
+		}
+	}
+
+	strict := NewCache(WithStrictValidation())
+	for i, v := range []struct {
+		data    []byte
+		variant int
+		err     error
+	}{
+		{in[:42], 1, ErrBlobTooShort},
+		{in, len(variants), ErrUnsupportedVariant},
+		{make([]byte, MaxBlobSize+1), 0, ErrBlobTooLong},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != v.err {
+					t.Errorf("[%d] expected to panic with %v, got %v\n", i, v.err, r)
+				}
+			}()
+
+			strict.Sum(v.data, v.variant)
+		}()
+
+		if _, err := strict.TrySum(v.data, v.variant); err != v.err {
+			t.Errorf("[%d] TrySum expected %v, got %v\n", i, v.err, err)
+		}
+	}
+}
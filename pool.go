@@ -1,7 +1,9 @@
 package cryptonight
 
 import (
+	"hash"
 	"sync"
+	"sync/atomic"
 
 	"github.com/aead/skein"
 	"github.com/dchest/blake256"
@@ -14,15 +16,175 @@ var (
 	// cachePool is a pool of cache.
 	cachePool = sync.Pool{
 		New: func() interface{} {
-			return new(cache)
+			return new(Cache)
 		},
 	}
 
-	// hashPool is for final hashes
-	hashPool = [...]*sync.Pool{
-		{New: func() interface{} { return blake256.New() }},
-		{New: func() interface{} { return groestl.New256() }},
-		{New: func() interface{} { return jh.New256() }},
-		{New: func() interface{} { return skein.New256(nil) }},
+	// newFinalHash creates the final hashes, each Cache owns one instance of
+	// them.
+	newFinalHash = [...]func() hash.Hash{
+		blake256.New,
+		groestl.New256,
+		jh.New256,
+		func() hash.Hash { return skein.New256(nil) },
 	}
 )
+
+// Pool is a pool of Cache, like the internal one used by the top-level Sum,
+// but it can keep a number of caches warm across GC cycles. The zero value of
+// Pool is ready to use, and it is safe for concurrent use.
+//
+// A sync.Pool is emptied by the GC, so when caches are only used now and then,
+// each Sum after a GC cycle has to allocate and fault in a fresh 2 MiB
+// scratchpad. KeepWarm avoids that pause at the cost of holding the memory.
+type Pool struct {
+	// counters of Stats, accessed atomically, first for 64-bit alignment on
+	// 32-bit platforms
+	hashes, gets, news uint64
+	inUse, maxInUse    int64
+
+	mu   sync.Mutex
+	warm []*Cache // caches that survive GC, at most keep of them
+	keep int
+
+	pool sync.Pool
+}
+
+// KeepWarm makes p retain at least n caches across GC cycles, allocating the
+// missing ones right away. It costs about 2 MiB of memory per cache for as
+// long as p lives. The scratchpads of the new caches are faulted in right away
+// too, so the first Sum on each doesn't pay for it. A zero n releases the
+// retained caches to the GC. A negative n panics.
+func (p *Pool) KeepWarm(n int) {
+	if n < 0 {
+		panic("cryptonight: negative number of caches to keep warm")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keep = n
+	for len(p.warm) < n {
+		cache := new(Cache)
+		cache.touchScratchpad()
+		atomic.AddUint64(&p.news, 1)
+		p.warm = append(p.warm, cache)
+	}
+	for len(p.warm) > n {
+		p.pool.Put(p.warm[len(p.warm)-1])
+		p.warm[len(p.warm)-1] = nil
+		p.warm = p.warm[:len(p.warm)-1]
+	}
+}
+
+// Get returns a Cache from p, allocating a new one if p is empty.
+func (p *Pool) Get() *Cache {
+	atomic.AddUint64(&p.gets, 1)
+	n := atomic.AddInt64(&p.inUse, 1)
+	for {
+		m := atomic.LoadInt64(&p.maxInUse)
+		if n <= m || atomic.CompareAndSwapInt64(&p.maxInUse, m, n) {
+			break
+		}
+	}
+
+	p.mu.Lock()
+	if w := len(p.warm); w > 0 {
+		cache := p.warm[w-1]
+		p.warm[w-1] = nil
+		p.warm = p.warm[:w-1]
+		p.mu.Unlock()
+		return cache
+	}
+	p.mu.Unlock()
+
+	if cache, ok := p.pool.Get().(*Cache); ok {
+		return cache
+	}
+	atomic.AddUint64(&p.news, 1)
+	return new(Cache)
+}
+
+// Put returns cache to p. cache must not be in use.
+func (p *Pool) Put(cache *Cache) {
+	atomic.AddInt64(&p.inUse, -1)
+
+	p.mu.Lock()
+	if len(p.warm) < p.keep {
+		p.warm = append(p.warm, cache)
+		p.mu.Unlock()
+		return
+	}
+	p.mu.Unlock()
+
+	p.pool.Put(cache)
+}
+
+// Sum calculates a CryptoNight hash digest using a Cache from p. It is
+// otherwise identical to the top-level Sum.
+func (p *Pool) Sum(data []byte, variant int) []byte {
+	cache := p.Get()
+	defer p.Put(cache)
+	sum := cache.Sum(data, variant)
+	atomic.AddUint64(&p.hashes, 1)
+
+	return sum
+}
+
+// SumAsync is like Sum, but it calculates the digest in a new goroutine, and
+// delivers it on the returned channel, which receives exactly one value. The
+// channel is buffered, so the goroutine finishes even if nobody receives.
+//
+// Every call holds a goroutine and a Cache of p, about 2 MiB, until its digest
+// is delivered. p doesn't limit how many caches it hands out, so SumAsync
+// doesn't apply backpressure on its own: a pipeline stage should bound the
+// calls in flight, such as to the caches p keeps warm. data must not be
+// modified until the digest arrives. SumAsync panics right away on an
+// unsupported variant, and with ErrBlobTooShort on data too short for it,
+// rather than in the goroutine, where the caller couldn't recover.
+func (p *Pool) SumAsync(data []byte, variant int) <-chan []byte {
+	if len(data) < paramsOf(variant).minLen {
+		panic(ErrBlobTooShort)
+	}
+
+	ch := make(chan []byte, 1)
+	go func() {
+		ch <- p.Sum(data, variant)
+	}()
+
+	return ch
+}
+
+// PoolStats are the counters of a Pool since it was created, for monitoring
+// how well it reuses caches, such as by exporting them to Prometheus.
+type PoolStats struct {
+	Hashes   uint64 // hash digests calculated by Pool.Sum
+	Gets     uint64 // caches handed out by Get, including those of Pool.Sum
+	News     uint64 // caches allocated, by Get or KeepWarm
+	MaxInUse uint64 // most caches handed out and not yet Put at once
+}
+
+// Stats returns the counters of p. They are kept with atomic operations on
+// every call, cheap next to a hash, and each is read atomically, though not
+// all of them at the same instant.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Hashes:   atomic.LoadUint64(&p.hashes),
+		Gets:     atomic.LoadUint64(&p.gets),
+		News:     atomic.LoadUint64(&p.news),
+		MaxInUse: uint64(atomic.LoadInt64(&p.maxInUse)),
+	}
+}
+
+// touchScratchpad writes a zero to every page of the scratchpad, so the OS
+// backs all of it with memory now instead of on first use. A fresh Cache is
+// otherwise mapped to the shared zero page until Sum writes to it, and the
+// first Sum takes the page faults of all 2 MiB.
+//
+// It clobbers the scratchpad, so cache must not be in use.
+func (cache *Cache) touchScratchpad() {
+	cache.allocScratchpad()
+	for i := 0; i < len(cache.scratchpad); i += 4096 / 8 {
+		cache.scratchpad[i] = 0
+	}
+}
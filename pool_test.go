@@ -0,0 +1,233 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	p := new(Pool)
+	v := hashSpecsV2[0]
+	in, _ := hex.DecodeString(v.input)
+	if result := p.Sum(in, v.variant); hex.EncodeToString(result) != v.output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%x\n", v.output, result)
+	}
+
+	p.KeepWarm(2)
+	x, y := p.Get(), p.Get()
+	if x == y {
+		t.Fatal("expected distinct caches")
+	}
+	p.Put(x)
+	p.Put(y)
+
+	// the warm caches survive GC cycles
+	runtime.GC()
+	runtime.GC()
+	if c := p.Get(); c != x && c != y {
+		t.Error("expected a warm cache after GC")
+	}
+
+	p.KeepWarm(0)
+	if len(p.warm) != 0 {
+		t.Errorf("expected no warm cache, got %d\n", len(p.warm))
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected to panic, got nothing.")
+		}
+	}()
+	p.KeepWarm(-1)
+}
+
+func TestPoolSumAsync(t *testing.T) {
+	p := new(Pool)
+
+	specs := []hashSpec{hashSpecsV0[1], hashSpecsV1[0], hashSpecsV2[0], hashSpecsV2[1]}
+	chs := make([]<-chan []byte, len(specs))
+	for i, v := range specs {
+		in, _ := hex.DecodeString(v.input)
+		chs[i] = p.SumAsync(in, v.variant)
+	}
+	for i, v := range specs {
+		if result := <-chs[i]; hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+	if s := p.Stats(); s.Hashes != uint64(len(specs)) {
+		t.Errorf("expected %d hashes, got %d\n", len(specs), s.Hashes)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != ErrBlobTooShort {
+				t.Fatalf("expected to panic with ErrBlobTooShort, got %v\n", r)
+			}
+		}()
+		p.SumAsync(make([]byte, 10), 1)
+	}()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected to panic, got nothing.")
+		}
+	}()
+	p.SumAsync(nil, len(variants))
+}
+
+func BenchmarkPoolGC(b *testing.B) {
+	// a GC cycle between every two hashes, as is the case for a pool only
+	// verifying a share now and then
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+
+	b.Run("sync.Pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			runtime.GC()
+			runtime.GC()
+			b.StartTimer()
+			Sum(in, 0)
+		}
+	})
+	b.Run("KeepWarm", func(b *testing.B) {
+		b.ReportAllocs()
+		p := new(Pool)
+		p.KeepWarm(1)
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			runtime.GC()
+			runtime.GC()
+			b.StartTimer()
+			p.Sum(in, 0)
+		}
+	})
+}
+
+// collectEvery forces a GC cycle every d, as a busy pool allocating for its
+// network and database work would, until the returned function is called.
+func collectEvery(d time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				runtime.GC()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// BenchmarkPoolPattern and BenchmarkFixedCaches verify shares concurrently
+// under periodic GC, the former taking caches from a Pool around each hash,
+// the latter keeping one Cache per goroutine for its whole life.
+func BenchmarkPoolPattern(b *testing.B) {
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+
+	b.ReportAllocs()
+	p := new(Pool)
+	p.KeepWarm(runtime.GOMAXPROCS(0))
+	stop := collectEvery(10 * time.Millisecond)
+	defer stop()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Sum(in, 2)
+		}
+	})
+}
+
+func BenchmarkFixedCaches(b *testing.B) {
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+
+	b.ReportAllocs()
+	stop := collectEvery(10 * time.Millisecond)
+	defer stop()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		cache := new(Cache)
+		for pb.Next() {
+			cache.Sum(in, 2)
+		}
+	})
+}
+
+func TestTouchScratchpad(t *testing.T) {
+	cache := new(Cache)
+	cache.touchScratchpad()
+	v := hashSpecsV2[0]
+	in, _ := hex.DecodeString(v.input)
+	if result := cache.Sum(in, v.variant); hex.EncodeToString(result) != v.output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%x\n", v.output, result)
+	}
+}
+
+func BenchmarkFirstSum(b *testing.B) {
+	// the first Sum on a fresh Cache, whose scratchpad is not backed by
+	// memory yet, against one faulted in beforehand by touchScratchpad; the
+	// difference is the cost of the page faults. FreeOSMemory makes sure the
+	// memory of the previous Cache is returned to the OS, not reused.
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			debug.FreeOSMemory()
+			cache := new(Cache)
+			b.StartTimer()
+			cache.Sum(in, 0)
+		}
+	})
+	b.Run("touched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			debug.FreeOSMemory()
+			cache := new(Cache)
+			cache.touchScratchpad()
+			b.StartTimer()
+			cache.Sum(in, 0)
+		}
+	})
+}
+
+func TestPoolStats(t *testing.T) {
+	p := new(Pool)
+	p.KeepWarm(1)
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	p.Sum(in, 0)
+	p.Sum(in, 0)
+
+	// two caches out at once, the warm one and a new one
+	x, y := p.Get(), p.Get()
+	p.Put(x)
+	p.Put(y)
+
+	expected := PoolStats{Hashes: 2, Gets: 4, News: 2, MaxInUse: 2}
+	if stats := p.Stats(); stats != expected {
+		t.Errorf("expected %+v, got %+v\n", expected, stats)
+	}
+
+	// a Sum that panics still returns its cache
+	func() {
+		defer func() { recover() }()
+		p.Sum(nil, 1)
+	}()
+	if p.inUse != 0 {
+		t.Errorf("expected no cache in use after a panic, got %d\n", p.inUse)
+	}
+}
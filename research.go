@@ -0,0 +1,124 @@
+package cryptonight
+
+import (
+	"ekyu.moe/cryptonight/internal/aes"
+	"ekyu.moe/cryptonight/internal/sha3"
+)
+
+// CustomParams describes a non-standard variant, for research and for
+// reproducing niche forks. Hashes calculated with CustomParams are not part of
+// any standard coin's consensus unless the parameters equal a standard
+// variant's.
+//
+// Start from CustomParamsOf and change the fields of interest.
+type CustomParams struct {
+	// Variant1 applies the tweak of variant 1.
+	Variant1 bool
+
+	// TweakOffset is where in the input data the 8 bytes of the variant 1
+	// tweak are read from. It is 35 for the standard variant 1, and 0 means
+	// 35 as well, so a tweak can't be read from the very start of the data.
+	// It must not exceed MaxBlobSize-8.
+	TweakOffset int
+
+	// Variant2 applies the shuffle and integer math of variant 2.
+	Variant2 bool
+
+	// SkipDivision, SkipSqrt and SkipShuffle turn off the integer division,
+	// the square root and the shuffle of variant 2 respectively, keeping
+	// the rest of it, for measuring what each step costs and contributes.
+	// Their results keep the values seeded from the keccak state. The
+	// standard variant 2 has all three steps on, and hashes with any of them
+	// skipped are non-consensus. They have no effect without Variant2.
+	SkipDivision, SkipSqrt, SkipShuffle bool
+
+	// SBox replaces the S-box of the AES used throughout the algorithm,
+	// including its key expansion. nil means the standard S-box. Hashing with
+	// a non-nil SBox always runs on the portable AES, as AES-NI can't use
+	// another S-box, and is thus much slower. Start from StandardSBox.
+	SBox *[256]byte
+
+	// FinalStateLen is how many bytes of the keccak state, from its start,
+	// feed the final hash. It is 200, the whole state, for every standard
+	// variant, and 0 means 200 as well. Any other length is non-consensus,
+	// for experimental chains that finalize only part of the state. It must
+	// not exceed 200.
+	FinalStateLen int
+
+	// KeccakRate is the rate in bytes of the keccak sponge that absorbs the
+	// input before the scratchpad initialization. It is 136 for every
+	// standard variant, and 0 means 136 as well. Any other rate is
+	// non-consensus, for researching the sponge parameters. It must be a
+	// multiple of 8 from 8 to 168, the rate of SHAKE128.
+	KeccakRate int
+}
+
+// StandardSBox returns the S-box of the standard AES, as a starting point for
+// CustomParams.SBox.
+func StandardSBox() [256]byte {
+	return aes.StandardSBox()
+}
+
+// CustomParamsOf returns the parameters of the standard variant, as a starting
+// point for customization. It panics on an unsupported variant, as Sum does.
+func CustomParamsOf(variant int) CustomParams {
+	p := paramsOf(variant)
+	return CustomParams{
+		Variant1:      p.variant1,
+		TweakOffset:   35,
+		Variant2:      p.variant2,
+		FinalStateLen: 200,
+		KeccakRate:    136,
+	}
+}
+
+// params validates c and converts it to the internal description of variants.
+func (c *CustomParams) params() (*variantParams, error) {
+	p := &variantParams{
+		variant1:    c.Variant1,
+		variant2:    c.Variant2,
+		noDivision:  c.SkipDivision,
+		noSqrt:      c.SkipSqrt,
+		noShuffle:   c.SkipShuffle,
+		selectFinal: selectFinalStandard,
+	}
+	if c.Variant1 {
+		if c.TweakOffset < 0 || c.TweakOffset > MaxBlobSize-8 {
+			return nil, ErrInvalidParams
+		}
+		p.tweakOffset = c.TweakOffset
+		if p.tweakOffset == 0 {
+			p.tweakOffset = 35
+		}
+		p.minLen = p.tweakOffset + 8
+	}
+	if c.FinalStateLen < 0 || c.FinalStateLen > 200 {
+		return nil, ErrInvalidParams
+	}
+	p.finalLen = c.FinalStateLen
+	if c.KeccakRate < 0 || c.KeccakRate > sha3.MaxRate || c.KeccakRate%8 != 0 {
+		return nil, ErrInvalidParams
+	}
+	p.keccakRate = c.KeccakRate
+	if c.SBox != nil {
+		p.tables = aes.NewTables(c.SBox)
+	}
+
+	return p, nil
+}
+
+// SumCustom calculates a CryptoNight hash digest with the custom parameters,
+// using cache. It returns ErrInvalidParams if params is invalid, or
+// ErrBlobTooShort if data is too short for params, such as for reading the
+// tweak of variant 1.
+func (cache *Cache) SumCustom(data []byte, params CustomParams) ([]byte, error) {
+	p, err := params.params()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < p.minLen {
+		return nil, ErrBlobTooShort
+	}
+
+	return cache.sum(data, p), nil
+}
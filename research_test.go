@@ -0,0 +1,225 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSumCustom(t *testing.T) {
+	cache := new(Cache)
+
+	// the defaults reproduce the standard variants
+	for _, v := range []hashSpec{hashSpecsV0[1], hashSpecsV1[0], hashSpecsV1[2], hashSpecsV2[0]} {
+		in, _ := hex.DecodeString(v.input)
+		result, err := cache.SumCustom(in, CustomParamsOf(v.variant))
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", v.variant, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.output, result)
+		}
+	}
+
+	// a tweak read from elsewhere gives the same as the standard variant 1,
+	// as long as the bytes there equal data[35:43]
+	in, _ := hex.DecodeString(hashSpecsV1[2].input)
+	for _, offset := range []int{1, 7, len(in) - 8} {
+		data := append([]byte(nil), in...)
+		copy(data[offset:], data[35:43])
+		p := CustomParamsOf(1)
+		p.TweakOffset = offset
+
+		got, err := cache.SumCustom(data, p)
+		if err != nil {
+			t.Fatalf("offset %d: unexpected error: %v\n", offset, err)
+		}
+		if expected := Sum(data, 1); !bytes.Equal(got, expected) {
+			t.Errorf("\noffset %d expected:\n\t%x\ngot:\n\t%x\n", offset, expected, got)
+		}
+
+		// and differs otherwise
+		data[offset] ^= 0xff
+		got, _ = cache.SumCustom(data, p)
+		if expected := Sum(data, 1); bytes.Equal(got, expected) {
+			t.Errorf("offset %d: expected a different digest\n", offset)
+		}
+	}
+
+	// a zero offset is the default of 35
+	result, err := cache.SumCustom(in, CustomParams{Variant1: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if hex.EncodeToString(result) != hashSpecsV1[2].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%x\n", hashSpecsV1[2].output, result)
+	}
+
+	p := CustomParamsOf(1)
+	p.TweakOffset = len(in) - 7
+	if _, err := cache.SumCustom(in, p); err != ErrBlobTooShort {
+		t.Errorf("expected ErrBlobTooShort, got %v\n", err)
+	}
+	for _, offset := range []int{-1, MaxBlobSize - 7, int(^uint(0) >> 1)} {
+		p.TweakOffset = offset
+		if _, err := cache.SumCustom(in, p); err != ErrInvalidParams {
+			t.Errorf("offset %d: expected ErrInvalidParams, got %v\n", offset, err)
+		}
+	}
+}
+
+func TestSumCustomSBox(t *testing.T) {
+	cache := new(Cache)
+	sbox := StandardSBox()
+
+	// the standard S-box, on the portable AES, gives exactly the same as Sum
+	for _, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		p := CustomParamsOf(v.variant)
+		p.SBox = &sbox
+
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", v.variant, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.output, result)
+		}
+	}
+
+	// any other S-box changes the digest
+	sbox[0], sbox[1] = sbox[1], sbox[0]
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	p := CustomParamsOf(2)
+	p.SBox = &sbox
+	result, _ := cache.SumCustom(in, p)
+	if hex.EncodeToString(result) == hashSpecsV2[0].output {
+		t.Error("expected a different digest with another S-box\n")
+	}
+	if again, _ := cache.SumCustom(in, p); !bytes.Equal(again, result) {
+		t.Errorf("\nexpected the same digest again:\n\t%x\ngot:\n\t%x\n", result, again)
+	}
+}
+
+func TestSumCustomVariant2Steps(t *testing.T) {
+	cache := new(Cache)
+
+	// with every step on, it is the standard variant 2
+	for i, v := range hashSpecsV2 {
+		in, _ := hex.DecodeString(v.input)
+		p := CustomParamsOf(2)
+		p.SkipDivision, p.SkipSqrt, p.SkipShuffle = false, false, false
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", i, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+
+	// each combination of skipped steps gives a digest of its own
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	seen := map[string]int{hashSpecsV2[0].output: 0}
+	for mask := 1; mask < 8; mask++ {
+		p := CustomParamsOf(2)
+		p.SkipDivision, p.SkipSqrt, p.SkipShuffle = mask&1 != 0, mask&2 != 0, mask&4 != 0
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", mask, err)
+		}
+		if prev, ok := seen[hex.EncodeToString(result)]; ok {
+			t.Errorf("[%d] expected a digest different from [%d]\n", mask, prev)
+		}
+		seen[hex.EncodeToString(result)] = mask
+	}
+
+	// and the steps mean nothing without variant 2
+	p := CustomParamsOf(0)
+	p.SkipDivision, p.SkipSqrt, p.SkipShuffle = true, true, true
+	if result, _ := cache.SumCustom(in, p); !bytes.Equal(result, Sum(in, 0)) {
+		t.Errorf("\nexpected:\n\t%x\ngot:\n\t%x\n", Sum(in, 0), result)
+	}
+}
+
+func TestSumCustomFinalStateLen(t *testing.T) {
+	cache := new(Cache)
+
+	// the whole state, by default or explicitly, is the standard variant
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	for _, n := range []int{0, 200} {
+		p := CustomParamsOf(2)
+		p.FinalStateLen = n
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", n, err)
+		}
+		if hex.EncodeToString(result) != hashSpecsV2[0].output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", n, hashSpecsV2[0].output, result)
+		}
+	}
+
+	// any part of it gives a digest of its own
+	seen := map[string]int{hashSpecsV2[0].output: 200}
+	for _, n := range []int{1, 32, 136, 199} {
+		p := CustomParamsOf(2)
+		p.FinalStateLen = n
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", n, err)
+		}
+		if prev, ok := seen[hex.EncodeToString(result)]; ok {
+			t.Errorf("[%d] expected a digest different from [%d]\n", n, prev)
+		}
+		seen[hex.EncodeToString(result)] = n
+	}
+
+	for _, n := range []int{-1, 201} {
+		p := CustomParamsOf(2)
+		p.FinalStateLen = n
+		if _, err := cache.SumCustom(in, p); err != ErrInvalidParams {
+			t.Errorf("[%d] expected ErrInvalidParams, got %v\n", n, err)
+		}
+	}
+}
+
+func TestSumCustomKeccakRate(t *testing.T) {
+	cache := new(Cache)
+
+	// the rate of 136, by default or explicitly, is the standard variant
+	in, _ := hex.DecodeString(hashSpecsV2[0].input)
+	for _, rate := range []int{0, 136} {
+		p := CustomParamsOf(2)
+		p.KeccakRate = rate
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", rate, err)
+		}
+		if hex.EncodeToString(result) != hashSpecsV2[0].output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", rate, hashSpecsV2[0].output, result)
+		}
+	}
+
+	// any other rate gives a digest of its own
+	seen := map[string]int{hashSpecsV2[0].output: 136}
+	for _, rate := range []int{8, 72, 104, 144, 168} {
+		p := CustomParamsOf(2)
+		p.KeccakRate = rate
+		result, err := cache.SumCustom(in, p)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", rate, err)
+		}
+		if prev, ok := seen[hex.EncodeToString(result)]; ok {
+			t.Errorf("[%d] expected a digest different from [%d]\n", rate, prev)
+		}
+		seen[hex.EncodeToString(result)] = rate
+	}
+
+	for _, rate := range []int{-8, 1, 135, 176, 200} {
+		p := CustomParamsOf(2)
+		p.KeccakRate = rate
+		if _, err := cache.SumCustom(in, p); err != ErrInvalidParams {
+			t.Errorf("[%d] expected ErrInvalidParams, got %v\n", rate, err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+// +build cryptonight_debug
+
+package cryptonight
+
+import "strconv"
+
+// checkScratchpadAddr panics unless addr, a word index into the scratchpad,
+// addresses a whole 16-byte block within the scratchpadSize bytes of p, so
+// that a wrong address mask of a new variant fails right where it happens,
+// instead of silently hashing the wrong memory.
+func checkScratchpadAddr(p *variantParams, addr uint64) {
+	if addr&1 != 0 || addr+2 > scratchpadSize/8 {
+		name := p.name
+		if name == "" {
+			name = "of CustomParams"
+		}
+		panic("cryptonight: scratchpad index " + strconv.FormatUint(addr, 10) + " out of bounds for variant " + name)
+	}
+}
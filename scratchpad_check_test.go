@@ -0,0 +1,36 @@
+// +build cryptonight_debug
+
+package cryptonight
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestScratchpadCheck(t *testing.T) {
+	// hashing passes every check
+	for _, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		if result := hex.EncodeToString(Sum(in, v.variant)); result != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", v.variant, v.output, result)
+		}
+	}
+
+	for _, addr := range []uint64{0, 2, scratchpadSize/8 - 2} {
+		checkScratchpadAddr(paramsOf(2), addr)
+	}
+
+	// a misaligned or out of bounds block panics, naming the variant
+	for _, addr := range []uint64{1, scratchpadSize/8 - 1, scratchpadSize / 8, addrMask} {
+		func() {
+			defer func() {
+				r, _ := recover().(string)
+				if !strings.Contains(r, "out of bounds for variant cn/2") {
+					t.Errorf("%#x: expected a panic naming cn/2, got %q\n", addr, r)
+				}
+			}()
+			checkScratchpadAddr(paramsOf(2), addr)
+		}()
+	}
+}
@@ -0,0 +1,7 @@
+// +build !cryptonight_debug
+
+package cryptonight
+
+// checkScratchpadAddr is a no-op outside of debug builds, see
+// scratchpad_check.go.
+func checkScratchpadAddr(p *variantParams, addr uint64) {}
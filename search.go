@@ -0,0 +1,147 @@
+package cryptonight
+
+import (
+	"context"
+	"encoding/binary"
+	"runtime"
+	"sync"
+)
+
+// SearchParallel searches for a nonce that makes blob meet the difficulty
+// target, as solo mining does. The nonce is written as a 32-bit little endian
+// integer at blob[nonceOffset:nonceOffset+4] of a private copy of blob; blob
+// itself is not modified.
+//
+// The nonces from startNonce up to math.MaxUint32 are split into workers
+// disjoint windows of consecutive nonces, each scanned by its own goroutine
+// and Cache, so no nonce is hashed twice. As soon as one worker finds a
+// solution, the others are stopped, and the nonce and the hash digest are
+// returned with found set. It is the first solution found, not necessarily the
+// smallest nonce. found is false if the whole range is exhausted, or if ctx is
+// done first, such as when the pool switches to a new job.
+//
+// workers of 0 means runtime.GOMAXPROCS(0). A negative workers panics with
+// ErrInvalidWorkers, and so does a blob too short to hold a nonce at
+// nonceOffset, or too short for variant, with ErrBlobTooShort, before any
+// worker starts.
+func SearchParallel(ctx context.Context, blob []byte, nonceOffset int, target uint64, variant int, workers int, startNonce uint32) (nonce uint32, sum []byte, found bool) {
+	if workers < 0 {
+		panic(ErrInvalidWorkers)
+	}
+	// checked here, as a panic in a worker is out of reach of the caller
+	p := paramsOf(variant)
+	if nonceOffset < 0 || nonceOffset+4 > len(blob) || len(blob) < p.minLen {
+		panic(ErrBlobTooShort)
+	}
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// the windows are [start, end), in uint64 to include math.MaxUint32
+	total := 1<<32 - uint64(startNonce)
+	if uint64(workers) > total {
+		workers = int(total)
+	}
+	span := total / uint64(workers)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once sync.Once
+		wg   sync.WaitGroup
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		start := uint64(startNonce) + uint64(w)*span
+		end := start + span
+		if w == workers-1 {
+			end = 1 << 32
+		}
+
+		go func() {
+			defer wg.Done()
+
+			n, s, ok := search(ctx, blob, nonceOffset, target, p, start, end)
+			if ok {
+				once.Do(func() {
+					nonce, sum, found = n, s, true
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nonce, sum, found
+}
+
+// search scans the nonces in [start, end) on a Cache of its own, until one
+// meets target or ctx is done.
+func search(ctx context.Context, blob []byte, nonceOffset int, target uint64, p *variantParams, start, end uint64) (uint32, []byte, bool) {
+	data := make([]byte, len(blob))
+	copy(data, blob)
+
+	cache := new(Cache)
+	for n := start; n < end; n++ {
+		select {
+		case <-ctx.Done():
+			return 0, nil, false
+		default:
+		}
+
+		binary.LittleEndian.PutUint32(data[nonceOffset:], uint32(n))
+		if sum := cache.sum(data, p); CheckHash(sum, target) {
+			return uint32(n), sum, true
+		}
+	}
+
+	return 0, nil, false
+}
+
+// SumIncr calculates the CryptoNight hash digest of blob with the nonce it
+// currently holds, then increments the nonce in place and returns the new
+// value, for miners that submit and continue. The nonce is a 32-bit little
+// endian integer at blob[nonceOffset:nonceOffset+4]; it wraps around from
+// math.MaxUint32 to 0. Apart from the digest, SumIncr doesn't allocate.
+//
+// SumIncr panics with ErrBlobTooShort if blob is too short to hold a nonce at
+// nonceOffset. The same requirement on input length of Sum applies to blob.
+func (cache *Cache) SumIncr(blob []byte, nonceOffset int, variant int) (sum []byte, nextNonce uint32) {
+	if nonceOffset < 0 || nonceOffset+4 > len(blob) {
+		panic(ErrBlobTooShort)
+	}
+
+	sum = cache.sum(blob, paramsOf(variant))
+	nextNonce = binary.LittleEndian.Uint32(blob[nonceOffset:]) + 1
+	binary.LittleEndian.PutUint32(blob[nonceOffset:], nextNonce)
+
+	return sum, nextNonce
+}
+
+// SumRange calculates the CryptoNight hash digests of blob for count nonces in
+// a row, starting from the nonce blob currently holds, and calls out with each
+// nonce and its digest. As SumIncr does, it increments the nonce in place, so
+// blob holds the nonce after the last one on return.
+//
+// The sum passed to out is a buffer reused for every nonce; out must copy it
+// if it retains the digest. Unlike a loop over SumIncr, it doesn't allocate a
+// digest per nonce.
+//
+// SumRange panics with ErrBlobTooShort if blob is too short to hold a nonce at
+// nonceOffset.
+func (cache *Cache) SumRange(blob []byte, nonceOffset int, count uint32, variant int, out func(nonce uint32, sum []byte)) {
+	if nonceOffset < 0 || nonceOffset+4 > len(blob) {
+		panic(ErrBlobTooShort)
+	}
+
+	p := paramsOf(variant)
+	var buf [32]byte
+	for i := uint32(0); i < count; i++ {
+		nonce := binary.LittleEndian.Uint32(blob[nonceOffset:])
+		sum := cache.sumAppend(buf[:0], blob, p)
+		binary.LittleEndian.PutUint32(blob[nonceOffset:], nonce+1)
+
+		out(nonce, sum)
+	}
+}
@@ -0,0 +1,155 @@
+package cryptonight
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSearchParallel(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+	orig := append([]byte(nil), blob...)
+
+	for _, workers := range []int{1, 3} {
+		nonce, sum, found := SearchParallel(context.Background(), blob, 39, 16, 2, workers, 1000)
+		if !found {
+			t.Fatalf("workers=%d: expected a solution\n", workers)
+		}
+		if nonce < 1000 {
+			t.Errorf("workers=%d: nonce %d below the start\n", workers, nonce)
+		}
+
+		data := append([]byte(nil), blob...)
+		binary.LittleEndian.PutUint32(data[39:], nonce)
+		if expected := Sum(data, 2); !bytes.Equal(sum, expected) || !CheckHash(sum, 16) {
+			t.Errorf("workers=%d nonce=%d: expected %x meeting the target, got %x\n", workers, nonce, expected, sum)
+		}
+	}
+
+	if !bytes.Equal(blob, orig) {
+		t.Error("blob was modified\n")
+	}
+}
+
+func TestSearchParallelWindows(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+
+	// two nonces left, with a target nothing meets; both must be tried and
+	// the search must end on its own
+	nonce, sum, found := SearchParallel(context.Background(), blob, 39, math.MaxUint64, 0, 4, math.MaxUint32-1)
+	if found || sum != nil || nonce != 0 {
+		t.Errorf("expected nothing found, got nonce %d, %x\n", nonce, sum)
+	}
+}
+
+func TestSearchParallelCancel(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan bool)
+	go func() {
+		_, _, found := SearchParallel(ctx, blob, 39, math.MaxUint64, 0, 2, 0)
+		done <- found
+	}()
+
+	select {
+	case found := <-done:
+		if found {
+			t.Error("expected nothing found\n")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("search did not stop after its context was done\n")
+	}
+}
+
+func TestSearchParallelPanics(t *testing.T) {
+	for i, v := range []struct {
+		blobLen, nonceOffset, variant, workers int
+		err                                    error
+	}{
+		{76, 39, 0, -1, ErrInvalidWorkers},
+		{42, 39, 0, 1, ErrBlobTooShort},
+		{76, -1, 0, 1, ErrBlobTooShort},
+		// long enough for the nonce, too short for variant 1
+		{40, 35, 1, 2, ErrBlobTooShort},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != v.err {
+					t.Errorf("[%d] expected to panic with %v, got %v\n", i, v.err, r)
+				}
+			}()
+
+			SearchParallel(context.Background(), make([]byte, v.blobLen), v.nonceOffset, 1, v.variant, v.workers, 0)
+		}()
+	}
+}
+
+func TestSumIncr(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+	cache := new(Cache)
+
+	for _, nonce := range []uint32{0, 41, math.MaxUint32} {
+		binary.LittleEndian.PutUint32(blob[39:], nonce)
+		expected := Sum(blob, 2)
+
+		sum, next := cache.SumIncr(blob, 39, 2)
+		if !bytes.Equal(sum, expected) {
+			t.Errorf("\nnonce %d expected:\n\t%x\ngot:\n\t%x\n", nonce, expected, sum)
+		}
+		if next != nonce+1 || binary.LittleEndian.Uint32(blob[39:]) != nonce+1 {
+			t.Errorf("nonce %d: expected the next nonce %d, got %d, blob holds %d\n", nonce, nonce+1, next, binary.LittleEndian.Uint32(blob[39:]))
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != ErrBlobTooShort {
+			t.Fatalf("expected to panic with ErrBlobTooShort, got %v\n", r)
+		}
+	}()
+	cache.SumIncr(blob, len(blob)-3, 2)
+}
+
+func TestSumRange(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+	data := append([]byte(nil), blob...)
+	binary.LittleEndian.PutUint32(blob[39:], math.MaxUint32-1)
+	cache := new(Cache)
+
+	var nonces []uint32
+	cache.SumRange(blob, 39, 3, 2, func(nonce uint32, sum []byte) {
+		nonces = append(nonces, nonce)
+
+		binary.LittleEndian.PutUint32(data[39:], nonce)
+		if expected := Sum(data, 2); !bytes.Equal(sum, expected) {
+			t.Errorf("\nnonce %d expected:\n\t%x\ngot:\n\t%x\n", nonce, expected, sum)
+		}
+	})
+
+	if expected := []uint32{math.MaxUint32 - 1, math.MaxUint32, 0}; len(nonces) != 3 || nonces[0] != expected[0] || nonces[1] != expected[1] || nonces[2] != expected[2] {
+		t.Errorf("expected nonces %v, got %v\n", expected, nonces)
+	}
+	if next := binary.LittleEndian.Uint32(blob[39:]); next != 1 {
+		t.Errorf("expected the blob to hold nonce 1, got %d\n", next)
+	}
+}
+
+func TestSumRangeAllocs(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV2[0].input)
+	cache := new(Cache)
+	out := func(uint32, []byte) {}
+
+	// each nonce costs less than a SumIncr, which allocates its digest
+	one := testing.AllocsPerRun(2, func() { cache.SumRange(blob, 39, 1, 0, out) })
+	four := testing.AllocsPerRun(2, func() { cache.SumRange(blob, 39, 4, 0, out) })
+	incr := testing.AllocsPerRun(2, func() { cache.SumIncr(blob, 39, 0) })
+	if perNonce := (four - one) / 3; perNonce >= incr {
+		t.Errorf("expected fewer allocations per nonce than SumIncr's %v, got %v\n", incr, perNonce)
+	}
+}
@@ -0,0 +1,24 @@
+package cryptonight
+
+// ShareResult is the evaluation of a share, ready to be marshaled into a
+// JSON-RPC response.
+type ShareResult struct {
+	Hash        Digest `json:"hash"`
+	Difficulty  uint64 `json:"difficulty"`
+	MeetsTarget bool   `json:"meetsTarget"`
+	Variant     int    `json:"variant"`
+}
+
+// EvaluateShare calculates the CryptoNight hash digest of blob using cache,
+// and evaluates it against the difficulty target, as a pool does for each
+// share. MeetsTarget is decided by CheckHash.
+func (cache *Cache) EvaluateShare(blob []byte, variant int, target uint64) ShareResult {
+	sum := cache.SumDigest(blob, variant)
+
+	return ShareResult{
+		Hash:        sum,
+		Difficulty:  sum.Difficulty(),
+		MeetsTarget: CheckHash(sum[:], target),
+		Variant:     variant,
+	}
+}
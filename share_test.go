@@ -0,0 +1,33 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestEvaluateShare(t *testing.T) {
+	cache := new(Cache)
+	v := hashSpecsV2[0]
+	in, _ := hex.DecodeString(v.input)
+	sum, _ := hex.DecodeString(v.output)
+	diff := Difficulty(sum)
+
+	r := cache.EvaluateShare(in, v.variant, diff)
+	if r.Hash.String() != v.output || r.Difficulty != diff || !r.MeetsTarget || r.Variant != v.variant {
+		t.Errorf("unexpected result: %+v\n", r)
+	}
+	if r = cache.EvaluateShare(in, v.variant, diff+1); r.MeetsTarget {
+		t.Errorf("expected not to meet target, got %+v\n", r)
+	}
+
+	text, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	expected := `{"hash":"` + v.output + `","difficulty":` + strconv.FormatUint(diff, 10) + `,"meetsTarget":false,"variant":2}`
+	if string(text) != expected {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", expected, text)
+	}
+}
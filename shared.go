@@ -0,0 +1,125 @@
+package cryptonight
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// CacheSize is the memory a Cache holds in bytes, its scratchpad included, not
+// counting the small instances of the final hashes. It is 2,097,816 bytes on
+// 64-bit platforms, the 2 MiB scratchpad and 664 bytes of the rest of Cache.
+const CacheSize = ScratchpadSize + int64(unsafe.Sizeof(Cache{}))
+
+// CachesWithin returns how many caches fit in budget bytes of memory, for
+// sizing a verifier to its container, such as a Pool kept warm with
+// KeepWarm. It returns 0 when not even one fits; CryptoNight can't be
+// calculated in less than about 2 MiB.
+//
+// The rest of the process needs memory too, so budget should be what is left
+// for hashing, not the whole limit of the container. The count is capped at
+// the largest int, which a budget beyond the address space of a 32-bit
+// platform would exceed.
+func CachesWithin(budget int64) int {
+	if budget < CacheSize {
+		return 0
+	}
+
+	const maxInt = int64(^uint(0) >> 1)
+	if n := budget / CacheSize; n < maxInt {
+		return int(n)
+	}
+	return int(maxInt)
+}
+
+// AutoTuneWorkers recommends how many goroutines should hash variant at once,
+// each with a Cache of its own, for sizing the worker pool of a miner or a
+// verifier at startup. The count fits memBudgetBytes as CachesWithin does, and
+// is at most GOMAXPROCS. It returns 0 when not even one Cache fits.
+//
+// Within those limits, AutoTuneWorkers runs a brief calibration: it hashes with
+// 1, 2, 4 and so on up to the limit of workers at once, two hashes each, and
+// stops doubling as soon as the total hashrate grows by less than a tenth,
+// which is when more workers only contend for the caches and memory of the
+// CPU. That takes a few hashes' time, under a second on a common CPU, and
+// allocates every Cache it may recommend. It panics on an unsupported variant,
+// as Sum does.
+func AutoTuneWorkers(variant int, memBudgetBytes uint64) int {
+	p := paramsOf(variant)
+
+	budget := int64(math.MaxInt64)
+	if memBudgetBytes < math.MaxInt64 {
+		budget = int64(memBudgetBytes)
+	}
+	limit := CachesWithin(budget)
+	if procs := runtime.GOMAXPROCS(0); limit > procs {
+		limit = procs
+	}
+	if limit <= 1 {
+		return limit
+	}
+
+	caches := make([]Cache, limit)
+	for i := range caches {
+		caches[i].touchScratchpad()
+	}
+
+	data := make([]byte, 76) // the size of a block hashing blob
+	best, bestRate := 1, calibrate(caches[:1], data, p)
+	for n := 2; best < limit; n *= 2 {
+		if n > limit {
+			n = limit
+		}
+		rate := calibrate(caches[:n], data, p)
+		if rate < bestRate*1.1 {
+			break
+		}
+		best, bestRate = n, rate
+	}
+
+	return best
+}
+
+// calibrate hashes data twice on each of caches at once, and returns the total
+// hashrate in hashes per second.
+func calibrate(caches []Cache, data []byte, p *variantParams) float64 {
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range caches {
+		wg.Add(1)
+		go func(cache *Cache) {
+			defer wg.Done()
+			cache.sum(data, p)
+			cache.sum(data, p)
+		}(&caches[i])
+	}
+	wg.Wait()
+
+	return float64(2*len(caches)) / time.Since(start).Seconds()
+}
+
+// SharedLoopCache is a single Cache shared by any number of goroutines, whose
+// Sum calls are serialized through a mutex. The zero value of SharedLoopCache
+// is ready to use.
+//
+// It is meant for verifiers in small containers, which must bound their
+// memory at one scratchpad no matter how many requests come in at once. The
+// trade-off is throughput: every Sum waits for those before it, so all of them
+// together hash on one core at a time, a few dozen hashes per second. When
+// the memory allows more than one Cache, see CachesWithin, a Pool with that
+// many caches kept warm scales with cores instead.
+type SharedLoopCache struct {
+	mu    sync.Mutex
+	cache Cache
+}
+
+// Sum calculates a CryptoNight hash digest using the shared Cache, waiting for
+// any Sum in progress first. It is otherwise identical to the top-level Sum.
+func (c *SharedLoopCache) Sum(data []byte, variant int) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.cache.Sum(data, variant)
+}
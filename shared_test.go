@@ -0,0 +1,102 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestAutoTuneWorkers(t *testing.T) {
+	for _, v := range []struct {
+		budget uint64
+		n      int
+	}{
+		{0, 0},
+		{ScratchpadSize, 0},
+		{uint64(CacheSize), 1},
+		{uint64(2*CacheSize - 1), 1},
+	} {
+		if n := AutoTuneWorkers(2, v.budget); n != v.n {
+			t.Errorf("%d: expected %d, got %d\n", v.budget, v.n, n)
+		}
+	}
+
+	// the calibration stays within both the memory and GOMAXPROCS
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, budget := range []uint64{3 * uint64(CacheSize), math.MaxUint64} {
+		limit := 4
+		if budget < 4*uint64(CacheSize) {
+			limit = 3
+		}
+		if n := AutoTuneWorkers(0, budget); n < 1 || n > limit {
+			t.Errorf("%d: expected 1 to %d workers, got %d\n", budget, limit, n)
+		}
+	}
+}
+
+func TestCacheSize(t *testing.T) {
+	// the documented size; when the layout of Cache changes, update it here
+	// and in the doc of CacheSize deliberately
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("the documented size is of 64-bit platforms")
+	}
+	if size := unsafe.Sizeof(Cache{}); size != 664 {
+		t.Errorf("expected Cache of 664 bytes besides the scratchpad, got %d\n", size)
+	}
+	if CacheSize != 2097816 {
+		t.Errorf("expected CacheSize of 2097816 bytes, got %d\n", CacheSize)
+	}
+}
+
+func TestCachesWithin(t *testing.T) {
+	// the most caches, capped at the largest int on 32-bit platforms
+	most := int64(math.MaxInt64 / CacheSize)
+	if maxInt := int64(^uint(0) >> 1); most > maxInt {
+		most = maxInt
+	}
+
+	for _, v := range []struct {
+		budget int64
+		n      int
+	}{
+		{0, 0},
+		{ScratchpadSize, 0},
+		{CacheSize, 1},
+		{2*CacheSize - 1, 1},
+		{512 << 20, int(512 << 20 / CacheSize)},
+		{math.MaxInt64, int(most)},
+	} {
+		if n := CachesWithin(v.budget); n != v.n {
+			t.Errorf("%d: expected %d, got %d\n", v.budget, v.n, n)
+		}
+	}
+
+	// 512 MiB leaves room for a couple hundred caches
+	if n := CachesWithin(512 << 20); n < 200 || n > 256 {
+		t.Errorf("expected about 250 caches in 512 MiB, got %d\n", n)
+	}
+}
+
+func TestSharedLoopCache(t *testing.T) {
+	var (
+		shared SharedLoopCache
+		wg     sync.WaitGroup
+	)
+
+	specs := []hashSpec{hashSpecsV0[1], hashSpecsV1[0], hashSpecsV2[0], hashSpecsV2[1]}
+	wg.Add(len(specs))
+	for _, v := range specs {
+		go func(v hashSpec) {
+			defer wg.Done()
+
+			in, _ := hex.DecodeString(v.input)
+			if result := hex.EncodeToString(shared.Sum(in, v.variant)); result != v.output {
+				t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", v.variant, v.output, result)
+			}
+		}(v)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,89 @@
+package cryptonight
+
+import "time"
+
+// StageTimings is the time spent in each stage of a single Sum.
+type StageTimings struct {
+	Init   time.Duration // scratchpad initialization, including the keccak state
+	Loop   time.Duration // memory-hard loop
+	Result time.Duration // result calculation, including the final hash
+}
+
+// SumTimed is like Sum, but it also measures the time spent in each stage, on
+// the monotonic clock.
+//
+// The measurement adds a small overhead to every call, so SumTimed is meant
+// for profiling on a specific CPU, not for the hot path.
+func (cache *Cache) SumTimed(data []byte, variant int) (sum []byte, timings StageTimings) {
+	p := paramsOf(variant)
+
+	cache.acquire()
+	defer cache.release()
+
+	start := time.Now()
+	cache.scratchpadInit(data, p)
+	var v1Tweak uint64
+	if p.variant1 {
+		v1Tweak = variant1Tweak(&cache.finalState, data, p.tweakOffset)
+	}
+	timings.Init = time.Since(start)
+
+	start = time.Now()
+	cache.memoryHardLoop(p, v1Tweak)
+	timings.Loop = time.Since(start)
+
+	start = time.Now()
+	sum = cache.resultCalc(p, nil)
+	timings.Result = time.Since(start)
+
+	return sum, timings
+}
+
+// SumThrottled is like Sum, but it caps the rate of hashing at maxHashrate
+// hashes per second for callers hashing in a loop, such as a miner on a laptop
+// that shouldn't overheat. After hashing, it sleeps for what is left of
+// 1/maxHashrate seconds, measured from the start of the call, so the pace
+// adapts to the real speed of the device. A maxHashrate of 0 or less means no
+// limit.
+//
+// Throttling is best-effort: the rate may fall below maxHashrate when hashing
+// itself is slower, and overshoot it slightly by the granularity of the
+// scheduler. Each call is paced on its own, so hashing from several goroutines
+// at once multiplies the rate by their number.
+func (cache *Cache) SumThrottled(data []byte, variant int, maxHashrate float64) []byte {
+	start := time.Now()
+	sum := cache.Sum(data, variant)
+	if maxHashrate > 0 {
+		interval := time.Duration(float64(time.Second) / maxHashrate)
+		if rest := interval - time.Since(start); rest > 0 {
+			time.Sleep(rest)
+		}
+	}
+
+	return sum
+}
+
+// MemoryBandwidthEstimate returns the memory traffic in bytes per second that
+// hashing with variant at hashrate hashes per second causes, for capacity
+// planning. It panics on an unsupported variant, as Sum does.
+//
+// The estimate counts whole 64-byte cache lines, as the memory system moves
+// them: the scratchpad is written once by its initialization, and read and
+// written once more by result calculation, which is 6 MiB; each of the 524288
+// iterations of the memory-hard loop reads and writes back the two lines it
+// addresses at random, which is 128 MiB. That is about 134 MiB per hash, the
+// same for every supported variant, as variant 2 only uses more of each line.
+//
+// This is traffic to the scratchpad. A core with 2 MiB of cache to itself
+// serves most of it from the cache, so DRAM sees it mostly once the
+// scratchpads of all cores no longer fit there.
+func MemoryBandwidthEstimate(variant int, hashrate float64) float64 {
+	paramsOf(variant)
+
+	const (
+		lineSize    = 64
+		hashTraffic = 3*scratchpadSize + iterations*2*2*lineSize
+	)
+
+	return hashrate * hashTraffic
+}
@@ -0,0 +1,61 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func TestSumTimed(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		result, timings := cache.SumTimed(in, v.variant)
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+		if timings.Init <= 0 || timings.Loop <= 0 || timings.Result <= 0 {
+			t.Errorf("[%d] expected positive timings, got %+v\n", i, timings)
+		}
+	}
+}
+
+func TestSumThrottled(t *testing.T) {
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	cache := new(Cache)
+
+	// 10 hashes per second allow 3 hashes in no less than 300ms
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if result := hex.EncodeToString(cache.SumThrottled(in, 0, 10)); result != hashSpecsV0[1].output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, hashSpecsV0[1].output, result)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected at least 300ms, got %v\n", elapsed)
+	}
+
+	// no limit hashes without sleeping
+	if result := hex.EncodeToString(cache.SumThrottled(in, 0, 0)); result != hashSpecsV0[1].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", hashSpecsV0[1].output, result)
+	}
+}
+
+func TestMemoryBandwidthEstimate(t *testing.T) {
+	// 134 MiB per hash
+	for variant := range variants {
+		if b := MemoryBandwidthEstimate(variant, 1); b != 134<<20 {
+			t.Errorf("[%d] expected %d bytes per hash, got %v\n", variant, 134<<20, b)
+		}
+		if b := MemoryBandwidthEstimate(variant, 250); b != 250*134<<20 {
+			t.Errorf("[%d] expected %v bytes per second, got %v\n", variant, float64(250*134<<20), b)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected to panic, got nothing.")
+		}
+	}()
+	MemoryBandwidthEstimate(len(variants), 1)
+}
@@ -0,0 +1,16 @@
+package cryptonight
+
+// SumTrace is like Sum, but it calls cb at the end of every iteration of the
+// memory-hard loop, as per CNS008 sec.4, with the iteration number, counting
+// from 0 to 524287, and the registers a and b as they enter the next iteration.
+// cb may skip iterations it isn't interested in, such as all but every k-th.
+//
+// SumTrace is meant for debuggers and for visualizing how CryptoNight works.
+// The calls make it much slower than Sum, so it shouldn't be used for normal
+// hashing. cb must not use cache.
+func (cache *Cache) SumTrace(data []byte, variant int, cb func(iter int, a, b [2]uint64)) []byte {
+	p := *paramsOf(variant)
+	p.trace = cb
+
+	return cache.sum(data, &p)
+}
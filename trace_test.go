@@ -0,0 +1,44 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSumTrace(t *testing.T) {
+	cache := new(Cache)
+	for _, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+
+		var (
+			calls   int
+			lastA   [2]uint64
+			lastB   [2]uint64
+			ordered = true
+		)
+		result := cache.SumTrace(in, v.variant, func(iter int, a, b [2]uint64) {
+			if iter != calls {
+				ordered = false
+			}
+			calls++
+			lastA, lastB = a, b
+		})
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", v.variant, v.output, result)
+		}
+		if calls != 524288 || !ordered {
+			t.Errorf("[%d] expected 524288 calls in order, got %d, ordered=%v\n", v.variant, calls, ordered)
+		}
+
+		// the last registers are those the loop ends with
+		p := paramsOf(v.variant)
+		cache.scratchpadInit(in, p)
+		var v1Tweak uint64
+		if p.variant1 {
+			v1Tweak = variant1Tweak(&cache.finalState, in, p.tweakOffset)
+		}
+		if a, b := cache.memoryHardLoop(p, v1Tweak); a != lastA || b != lastB {
+			t.Errorf("[%d] expected the last registers %x %x, got %x %x\n", v.variant, a, b, lastA, lastB)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package cryptonight
+
+// v2Division is VARIANT2_INTEGER_MATH_DIVISION_STEP of monero: it divides c1,
+// the second word of c, by a 32-bit divisor derived from c0 and the previous
+// square root, and packs the quotient and the remainder into the next
+// division result.
+//
+// The divisor is the low 32 bits of c0 + sqrtResult*2 with its highest and
+// lowest bit set, so it is odd, within [2^31+1, 2^32-1], and never zero. The
+// quotient may thus reach 2^33, and only its low 32 bits are kept; the
+// remainder is less than the divisor and fills the high 32 bits whole. This is
+// a single 64-by-32-bit division, which Go does natively on every target.
+func v2Division(c0, c1, sqrtResult uint64) uint64 {
+	divisor := (c0+sqrtResult<<1)&0xffffffff | 0x80000001
+	return (c1/divisor)&0xffffffff | (c1%divisor)<<32
+}
@@ -0,0 +1,46 @@
+package cryptonight
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestV2Division(t *testing.T) {
+	// pinned with arbitrary-precision integers, independently of the native
+	// division
+	for i, v := range []struct {
+		c0, c1, sqrtResult, output uint64
+	}{
+		{0x0, 0x0, 0x0, 0x0},                                             // divisor 0x80000001, quotient 0x0
+		{0x0, 0xffffffffffffffff, 0x0, 0x3fffffffc},                      // divisor 0x80000001, quotient 0x1fffffffc
+		{0xffffffff, 0xffffffffffffffff, 0x0, 0x1},                       // divisor 0xffffffff, quotient 0x100000001
+		{0x7ffffffe, 0x123456789abcdef0, 0x0, 0xacf1356812345678},        // divisor 0xffffffff, quotient 0x12345678
+		{0x12345678, 0xfedcba9876543210, 0x9abcdef0, 0x67b2c6b546bf16d3}, // divisor 0xc7ae1459, quotient 0x146bf16d3
+		{0xffffffffffffffff, 0x8000000000000000, 0x1, 0x2fffffffe},       // divisor 0x80000001, quotient 0xfffffffe
+		{0x0, 0x8000000280000008, 0x0, 0x500000003},                      // divisor 0x80000001, quotient 0x100000003
+	} {
+		if o := v2Division(v.c0, v.c1, v.sqrtResult); o != v.output {
+			t.Errorf("[%d] expected %#x, got %#x\n", i, v.output, o)
+		}
+	}
+
+	// and arbitrary inputs against math/big
+	var (
+		q, r, dividend, divisor big.Int
+		mask                    = new(big.Int).SetUint64(0xffffffff)
+	)
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := 0; i < 1<<16; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+		c0, c1, sqrtResult := x, x*0xbf58476d1ce4e5b9, x>>31
+
+		dividend.SetUint64(c1)
+		divisor.SetUint64((c0+sqrtResult*2)&0xffffffff | 0x80000001)
+		q.QuoRem(&dividend, &divisor, &r)
+		expected := q.And(&q, mask).Uint64() | r.Uint64()<<32
+
+		if o := v2Division(c0, c1, sqrtResult); o != expected {
+			t.Fatalf("%#x %#x %#x: expected %#x, got %#x\n", c0, c1, sqrtResult, expected, o)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cryptonight
+
+// variant2ShuffleGo is the pure Go implementation of variant2Shuffle.
+//
+// The 64-byte line holds four 16-byte chunks. Relative to the chunk at offset,
+// which is left alone, the other three rotate: chunk 1 moves to 2, 2 to 3 and
+// 3 to 1. In words of the line, for each offset (with zero a and b):
+//
+//	offset 0: 0 1 2 3 4 5 6 7 -> 0 1 6 7 2 3 4 5
+//	offset 2: 0 1 2 3 4 5 6 7 -> 4 5 2 3 6 7 0 1
+//	offset 4: 0 1 2 3 4 5 6 7 -> 6 7 0 1 4 5 2 3
+//	offset 6: 0 1 2 3 4 5 6 7 -> 2 3 4 5 0 1 6 7
+//
+// The chunk moved to 1 is added b[2:4], the one moved to 2 b[0:2], and the one
+// moved to 3 a.
+func variant2ShuffleGo(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64) {
+	// since we use []uint64 instead of []uint8 as scratchpad, the offset applies too
+	offset0 := offset ^ 0x02
+	offset1 := offset ^ 0x04
+	offset2 := offset ^ 0x06
+
+	chunk0, chunk1 := line[offset0], line[offset0+1]
+
+	line[offset0] = line[offset2] + b[2]
+	line[offset0+1] = line[offset2+1] + b[3]
+
+	line[offset2] = line[offset1] + a[0]
+	line[offset2+1] = line[offset1+1] + a[1]
+
+	line[offset1] = chunk0 + b[0]
+	line[offset1+1] = chunk1 + b[1]
+}
+
+// variant2ShuffleReverse is the shuffle of variant 2 with the roles of the
+// first and the third chunk swapped, as used by the reverse waltz variants.
+// The chunks are loaded in reverse order, but stored to the same places as
+// variant2Shuffle does.
+func variant2ShuffleReverse(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64) {
+	offset0 := offset ^ 0x02
+	offset1 := offset ^ 0x04
+	offset2 := offset ^ 0x06
+
+	chunk0, chunk1 := line[offset1], line[offset1+1]
+
+	line[offset0] += b[2]
+	line[offset0+1] += b[3]
+
+	line[offset1] = line[offset2] + b[0]
+	line[offset1+1] = line[offset2+1] + b[1]
+
+	line[offset2] = chunk0 + a[0]
+	line[offset2+1] = chunk1 + a[1]
+}
@@ -0,0 +1,8 @@
+package cryptonight
+
+// variant2Shuffle performs the shuffle of variant 2 over the three 16-byte
+// chunks in line that neighbour the one at offset, which is the index of the
+// current chunk within the 64-byte line, i.e. one of 0, 2, 4 and 6.
+//
+//go:noescape
+func variant2Shuffle(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64)
@@ -0,0 +1,32 @@
+#include "textflag.h"
+
+// func variant2Shuffle(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64)
+TEXT ·variant2Shuffle(SB), NOSPLIT, $0
+    MOVQ line+0(FP), AX
+    MOVQ offset+8(FP), BX
+    MOVQ a+16(FP), CX
+    MOVQ b+24(FP), DX
+
+    // byte offsets of the three chunks
+    SHLQ $3, BX
+    MOVQ BX, SI
+    XORQ $0x10, SI
+    MOVQ BX, DI
+    XORQ $0x20, DI
+    XORQ $0x30, BX
+
+    MOVOU (AX)(SI*1), X0
+    MOVOU (AX)(DI*1), X1
+    MOVOU (AX)(BX*1), X2
+    MOVOU 16(DX), X3
+    MOVOU (CX), X4
+    MOVOU (DX), X5
+
+    PADDQ X3, X2
+    PADDQ X4, X1
+    PADDQ X5, X0
+
+    MOVOU X2, (AX)(SI*1)
+    MOVOU X1, (AX)(BX*1)
+    MOVOU X0, (AX)(DI*1)
+    RET
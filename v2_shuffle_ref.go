@@ -0,0 +1,7 @@
+// +build !amd64
+
+package cryptonight
+
+func variant2Shuffle(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64) {
+	variant2ShuffleGo(line, offset, a, b)
+}
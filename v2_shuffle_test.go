@@ -0,0 +1,148 @@
+package cryptonight
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestVariant2Shuffle(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 1000; i++ {
+		var line, expected [8]uint64
+		var a [2]uint64
+		var b [4]uint64
+		for j := range line {
+			line[j] = rnd.Uint64()
+		}
+		for j := range a {
+			a[j] = rnd.Uint64()
+		}
+		for j := range b {
+			b[j] = rnd.Uint64()
+		}
+		offset := uint64(i&3) << 1
+
+		expected = line
+		variant2ShuffleGo(&expected, offset, &a, &b)
+		variant2Shuffle(&line, offset, &a, &b)
+		if line != expected {
+			t.Fatalf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, expected, line)
+		}
+	}
+}
+
+func TestVariant2ShufflePermutation(t *testing.T) {
+	// the mapping documented on variant2ShuffleGo
+	perms := [4][8]uint64{
+		{0, 1, 6, 7, 2, 3, 4, 5},
+		{4, 5, 2, 3, 6, 7, 0, 1},
+		{6, 7, 0, 1, 4, 5, 2, 3},
+		{2, 3, 4, 5, 0, 1, 6, 7},
+	}
+	a := [2]uint64{0x100, 0x200}
+	b := [4]uint64{0x300, 0x400, 0x500, 0x600}
+
+	impls := []struct {
+		name    string
+		shuffle func(*[8]uint64, uint64, *[2]uint64, *[4]uint64)
+	}{
+		{"go", variant2ShuffleGo},
+		{"dispatch", variant2Shuffle},
+	}
+	for _, impl := range impls {
+		for i, perm := range perms {
+			offset := uint64(i) << 1
+
+			line := [8]uint64{0, 1, 2, 3, 4, 5, 6, 7}
+			impl.shuffle(&line, offset, new([2]uint64), new([4]uint64))
+			if line != perm {
+				t.Errorf("%s offset %d: expected %v, got %v\n", impl.name, offset, perm, line)
+			}
+
+			// each moved chunk gets its own addend
+			expected := perm
+			expected[offset^2] += b[2]
+			expected[offset^2+1] += b[3]
+			expected[offset^4] += b[0]
+			expected[offset^4+1] += b[1]
+			expected[offset^6] += a[0]
+			expected[offset^6+1] += a[1]
+			line = [8]uint64{0, 1, 2, 3, 4, 5, 6, 7}
+			impl.shuffle(&line, offset, &a, &b)
+			if line != expected {
+				t.Errorf("%s offset %d: expected %x, got %x\n", impl.name, offset, expected, line)
+			}
+		}
+	}
+}
+
+// shuffleModel follows VARIANT2_SHUFFLE of xmrig literally, in byte offsets
+// of the 64-byte line, with its reverse flag.
+func shuffleModel(line *[8]uint64, offset uint64, a *[2]uint64, b *[4]uint64, reverse bool) {
+	load := func(off uint64) [2]uint64 {
+		i := (offset<<3 ^ off) >> 3
+		return [2]uint64{line[i], line[i+1]}
+	}
+	store := func(off uint64, x [2]uint64, y0, y1 uint64) {
+		i := (offset<<3 ^ off) >> 3
+		line[i], line[i+1] = x[0]+y0, x[1]+y1
+	}
+
+	off1, off3 := uint64(0x10), uint64(0x30)
+	if reverse {
+		off1, off3 = off3, off1
+	}
+	chunk1, chunk2, chunk3 := load(off1), load(0x20), load(off3)
+	store(0x10, chunk3, b[2], b[3])
+	store(0x20, chunk1, b[0], b[1])
+	store(0x30, chunk2, a[0], a[1])
+}
+
+func TestVariant2ShuffleModel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 1000; i++ {
+		var line [8]uint64
+		var a [2]uint64
+		var b [4]uint64
+		for j := range line {
+			line[j] = rnd.Uint64()
+		}
+		for j := range a {
+			a[j] = rnd.Uint64()
+		}
+		for j := range b {
+			b[j] = rnd.Uint64()
+		}
+		offset := uint64(i&3) << 1
+
+		for _, reverse := range []bool{false, true} {
+			expected, got := line, line
+			shuffleModel(&expected, offset, &a, &b, reverse)
+			if reverse {
+				variant2ShuffleReverse(&got, offset, &a, &b)
+			} else {
+				variant2ShuffleGo(&got, offset, &a, &b)
+			}
+			if got != expected {
+				t.Fatalf("\n[%d] reverse=%v expected:\n\t%x\ngot:\n\t%x\n", i, reverse, expected, got)
+			}
+		}
+	}
+}
+
+func BenchmarkVariant2Shuffle(b *testing.B) {
+	var line [8]uint64
+	var x [2]uint64
+	var y [4]uint64
+
+	b.Run("go", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			variant2ShuffleGo(&line, uint64(i&3)<<1, &x, &y)
+		}
+	})
+	b.Run("dispatch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			variant2Shuffle(&line, uint64(i&3)<<1, &x, &y)
+		}
+	})
+}
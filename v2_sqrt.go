@@ -1,9 +1,20 @@
+// +build !tinygo,!cryptonight_nofpu
+
 package cryptonight
 
 import (
 	"math"
 )
 
+// sqrtImpl names the implementation of v2Sqrt, for BuildInfo.
+const sqrtImpl = "fpu"
+
+// v2Sqrt is VARIANT2_INTEGER_MATH_SQRT_STEP_FP64 followed by
+// VARIANT2_INTEGER_MATH_SQRT_FIXUP. It is much faster than v2SqrtInteger on
+// any target with an FPU, and the fixup makes the output exact.
+//
+// Built with -tags cryptonight_debug, every result is also checked against
+// v2SqrtInteger, see checkSqrt.
 func v2Sqrt(in uint64) uint64 {
 	out := uint64(
 		math.Sqrt(
@@ -17,6 +28,7 @@ func v2Sqrt(in uint64) uint64 {
 	if r+b > in {
 		out--
 	}
+	checkSqrt(in, out)
 
 	return out
 }
@@ -0,0 +1,25 @@
+// +build cryptonight_debug
+
+package cryptonight
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// sqrtMismatches counts the results of the floating-point v2Sqrt that differ
+// from v2SqrtInteger, accessed atomically.
+var sqrtMismatches uint64
+
+// checkSqrt compares out, the result of the floating-point v2Sqrt for in, with
+// v2SqrtInteger, and logs the exact input of any divergence.
+//
+// The operand of math.Sqrt is always within [2^64, 2^65], so a conforming FPU
+// never sees a NaN or a denormal there; a soft-float or otherwise
+// non-conforming one shows up here as a divergence.
+func checkSqrt(in, out uint64) {
+	if ref := v2SqrtInteger(in); out != ref {
+		atomic.AddUint64(&sqrtMismatches, 1)
+		log.Printf("cryptonight: v2Sqrt(%#016x) = %d on the FPU, expected %d", in, out, ref)
+	}
+}
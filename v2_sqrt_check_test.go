@@ -0,0 +1,34 @@
+// +build cryptonight_debug,!tinygo,!cryptonight_nofpu
+
+package cryptonight
+
+import (
+	"encoding/hex"
+	"sync/atomic"
+	"testing"
+)
+
+func TestV2SqrtCheck(t *testing.T) {
+	before := atomic.LoadUint64(&sqrtMismatches)
+
+	// every iteration of the variant 2 vectors
+	for i, v := range hashSpecsV2 {
+		in, _ := hex.DecodeString(v.input)
+		if result := hex.EncodeToString(Sum(in, 2)); result != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%s\n", i, v.output, result)
+		}
+	}
+
+	// and arbitrary inputs, including both ends
+	v2Sqrt(0)
+	v2Sqrt(^uint64(0))
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := 0; i < 1<<20; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+		v2Sqrt(x)
+	}
+
+	if n := atomic.LoadUint64(&sqrtMismatches) - before; n != 0 {
+		t.Fatalf("expected no divergence of the FPU square root, got %d\n", n)
+	}
+}
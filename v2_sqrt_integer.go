@@ -0,0 +1,25 @@
+package cryptonight
+
+// v2SqrtInteger computes the same value as v2Sqrt with integer operations
+// only, i.e. the integer part of "sqrt(2^64 + in) * 2 - 2^33".
+//
+// This is a port of integer_square_root_v2 used by
+// VARIANT2_INTEGER_MATH_SQRT_STEP_REF in monero: src/crypto/slow-hash.c. The
+// initial r encodes the implicit 2^64, so in is never extended past 64 bits.
+func v2SqrtInteger(in uint64) uint64 {
+	r := uint64(1) << 63
+	for bit := uint64(1) << 60; bit != 0; bit >>= 2 {
+		if in >= r+bit {
+			in -= r + bit
+			r += bit << 1
+		}
+		r >>= 1
+	}
+
+	r <<= 1
+	if in > r>>1 {
+		r++
+	}
+
+	return r - 1<<33
+}
@@ -0,0 +1,12 @@
+// +build tinygo cryptonight_nofpu
+
+package cryptonight
+
+// sqrtImpl names the implementation of v2Sqrt, for BuildInfo.
+const sqrtImpl = "integer"
+
+// v2Sqrt falls back to the integer-only square root, so that the hot path
+// neither needs an FPU nor links math.Sqrt.
+func v2Sqrt(in uint64) uint64 {
+	return v2SqrtInteger(in)
+}
@@ -48,3 +48,38 @@ func TestV2Sqrt(t *testing.T) {
 		}
 	}
 }
+
+func TestV2SqrtInteger(t *testing.T) {
+	if o := v2SqrtInteger(0); o != 0 {
+		t.Fatalf("expected 0, got %v\n", o)
+	}
+	if o := v2SqrtInteger(^uint64(0)); o != 3558067407 {
+		t.Fatalf("expected 3558067407, got %v\n", o)
+	}
+
+	// v2Sqrt is verified exhaustively above, compare the two around a sample
+	// of thresholds and on arbitrary inputs. Build with -tags cryptonight_nofpu
+	// to run the exhaustive test against v2SqrtInteger instead.
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := 0; i < 1<<20; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+		for _, in := range []uint64{x, x - 1, x + 1} {
+			if o, e := v2SqrtInteger(in), v2Sqrt(in); o != e {
+				t.Fatalf("%#x: expected %v, got %v\n", in, e, o)
+			}
+		}
+
+		j := x%3558067407 + 1
+		j0 := j >> 1
+		n1 := j0*j0 + (j << 32) - 1
+		if j&1 == 1 {
+			n1 = j0*j0 + j0 + (j << 32)
+		}
+		if o := v2SqrtInteger(n1); o != j-1 {
+			t.Fatalf("expected %v, got %v\n", j-1, o)
+		}
+		if o := v2SqrtInteger(n1 + 1); o != j {
+			t.Fatalf("expected %v, got %v\n", j, o)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package cryptonight
+
+import (
+	"strings"
+
+	"ekyu.moe/cryptonight/internal/aes"
+)
+
+// variantParams describes how a variant differs from the original CryptoNight.
+//
+// Every supported variant is an entry of variants, so adding a fork that only
+// differs in these parameters, such as one with a non-standard selection of
+// the final hash, is a data change rather than a code change. This is an
+// advanced and experimental mechanism; the standard variants always use the
+// canonical parameters defined below.
+type variantParams struct {
+	name        string // canonical, xmrig-style name of the algorithm
+	variant1    bool   // applies the variant 1 tweak
+	variant2    bool   // applies the shuffle and integer math of variant 2
+	minLen      int    // minimal length of input data
+	tweakOffset int    // where in the input data the variant 1 tweak is read
+	xtl         bool   // picks the variant 1 tweak bits as cn/xtl does
+
+	// noDivision, noSqrt and noShuffle turn off single steps of variant 2,
+	// for research only. All of them are false for every variant of variants.
+	noDivision, noSqrt, noShuffle bool
+
+	// selectFinal picks the final hash from the keccak state after result
+	// calculation. It returns an index of newFinalHash.
+	selectFinal func(state *[25]uint64) int
+
+	// tables replace the AES with one of a different S-box, for research
+	// only. nil means the standard AES, which is the case for every variant
+	// of variants.
+	tables *aes.Tables
+
+	// finalLen is how many bytes of the keccak state feed the final hash,
+	// for research only. 0 means all 200 of them, which is the case for
+	// every variant of variants.
+	finalLen int
+
+	// keccakRate is the rate in bytes of the sponge absorbing the input, for
+	// research only. 0 means 136, which is the case for every variant of
+	// variants.
+	keccakRate int
+
+	// trace is called at the end of every iteration of the memory-hard loop
+	// with the registers, for debugging only. nil for every variant of
+	// variants.
+	trace func(iter int, a, b [2]uint64)
+}
+
+var variants = [...]variantParams{
+	{name: "cn/0", selectFinal: selectFinalStandard},
+	{name: "cn/1", variant1: true, minLen: 43, tweakOffset: 35, selectFinal: selectFinalStandard},
+	{name: "cn/2", variant2: true, selectFinal: selectFinalStandard},
+}
+
+// variantAliases are the other names pools and miners advertise the supported
+// variants with, in addition to the canonical ones in variants.
+var variantAliases = map[string]int{
+	"cn":                   0,
+	"cryptonight":          0,
+	"cryptonight/0":        0,
+	"cryptonight/1":        1,
+	"cryptonight-monerov7": 1,
+	"cryptonight_v7":       1,
+	"cryptonight/2":        2,
+	"cryptonight-monerov8": 2,
+	"cryptonight_v8":       2,
+}
+
+// SupportedVariants returns every variant this package implements, in
+// ascending order. Each of them is a valid variant argument to Sum.
+func SupportedVariants() []int {
+	vs := make([]int, len(variants))
+	for i := range vs {
+		vs[i] = i
+	}
+
+	return vs
+}
+
+// VariantFromString returns the variant named by algo, an algorithm identifier
+// as sent by pools, such as "cn/2". Both the canonical xmrig-style names and
+// their common aliases are recognized, case-insensitively. Algorithms this
+// package doesn't implement, such as "cn/r" or "cn-lite/1", return
+// ErrUnsupportedVariant.
+func VariantFromString(algo string) (variant int, err error) {
+	algo = strings.ToLower(algo)
+	for i := range variants {
+		if variants[i].name == algo {
+			return i, nil
+		}
+	}
+	if v, ok := variantAliases[algo]; ok {
+		return v, nil
+	}
+
+	return 0, ErrUnsupportedVariant
+}
+
+// ParseJob translates the algorithm name and the block height of a pool job
+// into the variant to hash it with, as the single entry a Stratum client needs.
+// It is VariantFromString, plus needsHeight, which reports whether hashing
+// also depends on the height, so a client can check that the job carried one.
+//
+// No supported variant depends on the height, so needsHeight is always false
+// for now and height is not used. Height-dependent algorithms such as cn/r
+// return ErrUnsupportedVariant, as does any other algorithm this package
+// doesn't implement, such as cn-lite/1 or cn-heavy/xhv.
+func ParseJob(algo string, height uint64) (variant int, needsHeight bool, err error) {
+	variant, err = VariantFromString(algo)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return variant, false, nil
+}
+
+// VariantString returns the canonical, xmrig-style name of variant, such as
+// "cn/2", for logs and display. It is the inverse of VariantFromString, and
+// returns "unknown" for a variant this package doesn't implement.
+func VariantString(variant int) string {
+	if variant < 0 || variant >= len(variants) {
+		return "unknown"
+	}
+
+	return variants[variant].name
+}
+
+// paramsOf returns the parameters of variant. It panics with
+// ErrUnsupportedVariant on an unsupported variant, rather than silently
+// computing the digest of another one.
+func paramsOf(variant int) *variantParams {
+	if variant < 0 || variant >= len(variants) {
+		panic(ErrUnsupportedVariant)
+	}
+
+	return &variants[variant]
+}
+
+// selectFinalStandard is the four-way selection of CNS008 sec.5.
+func selectFinalStandard(state *[25]uint64) int {
+	return int(state[0] & 0x03)
+}
+
+// expandKey is aes.CnExpandKey with the AES of p.
+func (p *variantParams) expandKey(key []uint64, rkeys *aes.CnKeySchedule) {
+	if p.tables != nil {
+		p.tables.CnExpandKey(key, rkeys)
+		return
+	}
+	aes.CnExpandKey(key, rkeys)
+}
+
+// rounds is aes.CnRounds with the AES of p.
+func (p *variantParams) rounds(dst, src []uint64, rkeys *aes.CnKeySchedule) {
+	if p.tables != nil {
+		p.tables.CnRounds(dst, src, rkeys)
+		return
+	}
+	aes.CnRounds(dst, src, rkeys)
+}
+
+// singleRound is aes.CnSingleRound with the AES of p.
+func (p *variantParams) singleRound(dst, src []uint64, rkey *[2]uint64) {
+	if p.tables != nil {
+		p.tables.CnSingleRound(dst, src, rkey)
+		return
+	}
+	aes.CnSingleRound(dst, src, rkey)
+}
@@ -0,0 +1,82 @@
+package cryptonight
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSupportedVariants(t *testing.T) {
+	if vs := SupportedVariants(); !reflect.DeepEqual(vs, []int{0, 1, 2}) {
+		t.Errorf("expected [0 1 2], got %v\n", vs)
+	}
+}
+
+func TestVariantFromString(t *testing.T) {
+	for i, v := range []struct {
+		algo    string
+		variant int
+		err     error
+	}{
+		{"cn/0", 0, nil},
+		{"cn/1", 1, nil},
+		{"cn/2", 2, nil},
+		{"CN/2", 2, nil},
+		{"cryptonight", 0, nil},
+		{"cryptonight-monerov7", 1, nil},
+		{"cryptonight_v8", 2, nil},
+		{"cn/r", 0, ErrUnsupportedVariant},
+		{"cn-lite/1", 0, ErrUnsupportedVariant},
+		{"cn-heavy/xhv", 0, ErrUnsupportedVariant},
+		{"", 0, ErrUnsupportedVariant},
+	} {
+		variant, err := VariantFromString(v.algo)
+		if err != v.err || variant != v.variant {
+			t.Errorf("[%d] %q: expected (%d, %v), got (%d, %v)\n", i, v.algo, v.variant, v.err, variant, err)
+		}
+	}
+}
+
+func TestParseJob(t *testing.T) {
+	for i, v := range []struct {
+		algo        string
+		height      uint64
+		variant     int
+		needsHeight bool
+		err         error
+	}{
+		{"cn/2", 1806260, 2, false, nil},
+		{"cn/2", 0, 2, false, nil},
+		{"cryptonight-monerov7", 1600000, 1, false, nil},
+		{"cn/r", 1806260, 0, false, ErrUnsupportedVariant},
+		{"cn-lite/1", 0, 0, false, ErrUnsupportedVariant},
+		{"cn-heavy/xhv", 0, 0, false, ErrUnsupportedVariant},
+	} {
+		variant, needsHeight, err := ParseJob(v.algo, v.height)
+		if err != v.err || variant != v.variant || needsHeight != v.needsHeight {
+			t.Errorf("[%d] %q: expected (%d, %v, %v), got (%d, %v, %v)\n", i, v.algo, v.variant, v.needsHeight, v.err, variant, needsHeight, err)
+		}
+	}
+}
+
+func TestVariantString(t *testing.T) {
+	for i, v := range []struct {
+		variant int
+		name    string
+	}{
+		{0, "cn/0"},
+		{1, "cn/1"},
+		{2, "cn/2"},
+		{-1, "unknown"},
+		{len(variants), "unknown"},
+	} {
+		if name := VariantString(v.variant); name != v.name {
+			t.Errorf("[%d] expected %q, got %q\n", i, v.name, name)
+		}
+	}
+
+	for _, variant := range SupportedVariants() {
+		if got, err := VariantFromString(VariantString(variant)); err != nil || got != variant {
+			t.Errorf("variant %d: round trip gives (%d, %v)\n", variant, got, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package cryptonight
+
+import "bytes"
+
+// SumVerified is like Sum, but it calculates the digest twice and compares
+// the results, returning ErrHardwareMismatch if they differ.
+//
+// SumVerified costs twice as much as Sum. It is meant for detecting flaky
+// hardware, either on every share of a paranoid deployment or on a periodic
+// sample of them.
+func (cache *Cache) SumVerified(data []byte, variant int) ([]byte, error) {
+	return cache.sumVerified(data, paramsOf(variant))
+}
+
+func (cache *Cache) sumVerified(data []byte, p *variantParams) ([]byte, error) {
+	sum := cache.sum(data, p)
+	if !bytes.Equal(sum, cache.sum(data, p)) {
+		return nil, ErrHardwareMismatch
+	}
+
+	return sum, nil
+}
@@ -0,0 +1,35 @@
+package cryptonight
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestSumVerified(t *testing.T) {
+	cache := new(Cache)
+	for i, v := range hashSpecsSample {
+		in, _ := hex.DecodeString(v.input)
+		result, err := cache.SumVerified(in, v.variant)
+		if err != nil {
+			t.Fatalf("[%d] unexpected error: %v\n", i, err)
+		}
+		if hex.EncodeToString(result) != v.output {
+			t.Errorf("\n[%d] expected:\n\t%s\ngot:\n\t%x\n", i, v.output, result)
+		}
+	}
+}
+
+func TestSumVerifiedMismatch(t *testing.T) {
+	// simulate a flaky rig by picking a different final hash on every run
+	n := 0
+	p := variants[0]
+	p.selectFinal = func(*[25]uint64) int {
+		n++
+		return n & 0x03
+	}
+
+	in, _ := hex.DecodeString(hashSpecsV0[1].input)
+	if result, err := new(Cache).sumVerified(in, &p); err != ErrHardwareMismatch {
+		t.Fatalf("expected ErrHardwareMismatch, got %x, %v\n", result, err)
+	}
+}
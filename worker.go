@@ -0,0 +1,76 @@
+package cryptonight
+
+import "encoding/binary"
+
+// Worker is the inner loop of a miner: a Cache, a private copy of the hashing
+// blob of the current job, the nonce to try next, and the difficulty target.
+// Once a job is set, Step makes no heap allocation.
+//
+// A Worker is not concurrent safe, and must be used by a single goroutine.
+// Run one Worker per goroutine to mine in parallel, giving each a disjoint
+// range of nonces with SetNonce.
+type Worker struct {
+	cache  *Cache
+	p      *variantParams
+	blob   []byte // private copy of the job's blob, holding the nonce
+	target uint64
+	nonce  uint32
+	sum    [32]byte // digest of the last Step
+}
+
+// NewWorker returns a Worker hashing with variant, with no job yet. It panics
+// on an unsupported variant, as Sum does.
+func NewWorker(variant int) *Worker {
+	return &Worker{
+		cache: new(Cache),
+		p:     paramsOf(variant),
+	}
+}
+
+// SetJob switches w to a new job: blob is the hashing blob, with the nonce at
+// NonceOffset, and target is the difficulty a digest must meet, as checked by
+// CheckHash. w keeps a copy of blob, reusing its buffer across jobs, so blob
+// may be modified afterwards. The next Step tries the nonce blob holds.
+//
+// SetJob panics with ErrBlobTooShort if blob is too short to hold a nonce at
+// NonceOffset, or for the variant of w.
+func (w *Worker) SetJob(blob []byte, target uint64) {
+	if len(blob) < NonceOffset+4 || len(blob) < w.p.minLen {
+		panic(ErrBlobTooShort)
+	}
+
+	w.blob = append(w.blob[:0], blob...)
+	w.target = target
+	w.nonce = binary.LittleEndian.Uint32(blob[NonceOffset:])
+}
+
+// SetNonce sets the nonce the next Step tries, such as the start of the range
+// of nonces assigned to w.
+func (w *Worker) SetNonce(nonce uint32) {
+	w.nonce = nonce
+}
+
+// Nonce returns the nonce the next Step tries.
+func (w *Worker) Nonce() uint32 {
+	return w.nonce
+}
+
+// Step hashes the blob of the current job with the next nonce, and advances
+// to the nonce after it, wrapping around from math.MaxUint32 to 0. found
+// reports whether sum meets the target.
+//
+// sum is a buffer owned by w and overwritten by the next Step; the caller
+// must copy it to retain it, such as for submitting a share. Step panics if no
+// job is set.
+func (w *Worker) Step() (found bool, nonce uint32, sum []byte) {
+	if w.blob == nil {
+		panic("cryptonight: Step of a Worker without a job")
+	}
+
+	nonce = w.nonce
+	binary.LittleEndian.PutUint32(w.blob[NonceOffset:], nonce)
+	sum = w.cache.sumAppend(w.sum[:0], w.blob, w.p)
+	w.nonce++
+
+	return CheckHash(sum, w.target), nonce, sum
+}
@@ -0,0 +1,70 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestWorker(t *testing.T) {
+	blob, _ := hex.DecodeString(hashSpecsV1[2].input)
+	orig := append([]byte(nil), blob...)
+
+	w := NewWorker(1)
+	w.SetJob(blob, 1)
+	if nonce := binary.LittleEndian.Uint32(blob[NonceOffset:]); w.Nonce() != nonce {
+		t.Errorf("expected to start from nonce %d, got %d\n", nonce, w.Nonce())
+	}
+
+	// every nonce meets a difficulty of 1, and is the digest Sum gives
+	w.SetNonce(math.MaxUint32)
+	for _, expected := range []uint32{math.MaxUint32, 0} {
+		found, nonce, sum := w.Step()
+		if !found || nonce != expected {
+			t.Fatalf("expected nonce %d found, got %d, %v\n", expected, nonce, found)
+		}
+
+		data := append([]byte(nil), blob...)
+		binary.LittleEndian.PutUint32(data[NonceOffset:], nonce)
+		if result := Sum(data, 1); !bytes.Equal(sum, result) {
+			t.Errorf("\nnonce %d expected:\n\t%x\ngot:\n\t%x\n", nonce, result, sum)
+		}
+	}
+	if w.Nonce() != 1 {
+		t.Errorf("expected the next nonce 1, got %d\n", w.Nonce())
+	}
+
+	// and nothing meets the maximal one
+	w.SetJob(blob, math.MaxUint64)
+	if found, _, _ := w.Step(); found {
+		t.Error("expected nothing found\n")
+	}
+
+	if !bytes.Equal(blob, orig) {
+		t.Error("blob was modified\n")
+	}
+
+	if n := testing.AllocsPerRun(2, func() { w.Step() }); n != 0 {
+		t.Errorf("expected no allocation, got %v\n", n)
+	}
+}
+
+func TestWorkerPanics(t *testing.T) {
+	for i, f := range []func(){
+		func() { NewWorker(len(variants)) },
+		func() { NewWorker(0).SetJob(make([]byte, NonceOffset+3), 1) },
+		func() { NewWorker(1).SetJob(make([]byte, 42), 1) },
+		func() { NewWorker(0).Step() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("[%d] expected a panic\n", i)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,48 @@
+package cryptonight
+
+// xtlParams is cn/xtl, variant 1 with the tweak bits of Stellite.
+var xtlParams = variantParams{
+	name:        "cn/xtl",
+	variant1:    true,
+	xtl:         true,
+	minLen:      43,
+	tweakOffset: 35,
+	selectFinal: selectFinalStandard,
+}
+
+// SumXTL calculates the CryptoNight hash digest of data with cn/xtl, the
+// variant Stellite forked to from variant 1 and has since moved on from. It is
+// only meant for verifying the historical blocks of Stellite that are hashed
+// with it.
+//
+// cn/xtl is variant 1, except that VARIANT1_1 picks the bits it flips in byte
+// 11 of each written block by bit 0, 5 and 6 of that byte, instead of bit 0, 4
+// and 5. As for variant 1, data must have at least 43 bytes, otherwise SumXTL
+// panics.
+//
+// cn/xtl is not one of SupportedVariants; it has no variant number of its own.
+func SumXTL(data []byte) []byte {
+	cache := cachePool.Get().(*Cache)
+	sum := cache.SumXTL(data)
+	cachePool.Put(cache)
+
+	return sum
+}
+
+// SumXTL is like the top-level SumXTL, but uses cache as its working memory.
+func (cache *Cache) SumXTL(data []byte) []byte {
+	return cache.sum(data, &xtlParams)
+}
+
+// variantXTLMask is variant1Mask of cn/xtl, which reads bit 0, 5 and 6 of
+// byte 11, and flips bit 4 and 5 by the union of:
+//
+//   - ((^t) & 1) << 4 flips bit 4 if bit 0 is clear
+//   - (((^t) & 1) << 5) & t flips bit 5 if bit 0 is clear and bit 5 is set
+//   - (t & 64) >> 2 flips bit 4 if bit 6 is set
+//
+// This is equivalent to the lookup into 0x75310 with an index shift of 4
+// instead of 3, as in xmrig.
+func variantXTLMask(t uint64) uint64 {
+	return (((^t) & 1) << 4) | ((((^t) & 1) << 5) & t) | ((t & 64) >> 2)
+}
@@ -0,0 +1,61 @@
+package cryptonight
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestVariantXTLMask(t *testing.T) {
+	// the reference is VARIANT1_1 of xmrig with the index shift of cn/xtl
+	ref := func(tmp byte) byte {
+		const table uint32 = 0x75310
+		index := (((tmp >> 4) & 6) | (tmp & 1)) << 1
+		return tmp ^ byte((table>>index)&0x30)
+	}
+
+	for i := 0; i < 256; i++ {
+		tmp := byte(i)
+		for _, high := range []uint64{0, 0xffffff00, 0x123456700} {
+			if got := tmp ^ byte(variantXTLMask(high|uint64(tmp))); got != ref(tmp) {
+				t.Errorf("%#02x: expected %#02x, got %#02x\n", tmp, ref(tmp), got)
+			}
+		}
+	}
+}
+
+func TestSumXTL(t *testing.T) {
+	cache := new(Cache)
+
+	// the first input of the test vectors of xmrig, a Monero hashing blob,
+	// and its digest from test_output_xtl of xmrig
+	in, _ := hex.DecodeString("0305a0dbd6bf05cf16e503f3a66f78007cbf34144332ecbfc22ed95c8700383b309ace1923a0964b00000008ba939a62724c0d7581fce5761e9d8a0e6a1c3f924fdd8493d1115649c05eb601")
+	const expected = "8fe5f05f022a617de53f79364b25cbc3c08e0e1fe3be48570703fee1ec0eb0b1"
+	if result := hex.EncodeToString(SumXTL(in)); result != expected {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", expected, result)
+	}
+
+	for i, v := range hashSpecsV1 {
+		in, _ := hex.DecodeString(v.input)
+		result := SumXTL(in)
+		if hex.EncodeToString(result) == v.output {
+			t.Errorf("[%d] expected a digest different from variant 1\n", i)
+		}
+		if again := cache.SumXTL(in); !bytes.Equal(again, result) {
+			t.Errorf("\n[%d] expected:\n\t%x\ngot:\n\t%x\n", i, result, again)
+		}
+	}
+
+	// and cn/xtl leaves the standard variant 1 of the same Cache alone
+	in, _ = hex.DecodeString(hashSpecsV1[2].input)
+	if result := hex.EncodeToString(cache.Sum(in, 1)); result != hashSpecsV1[2].output {
+		t.Errorf("\nexpected:\n\t%s\ngot:\n\t%s\n", hashSpecsV1[2].output, result)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic on 42 bytes\n")
+		}
+	}()
+	SumXTL(make([]byte, 42))
+}